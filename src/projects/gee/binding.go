@@ -0,0 +1,485 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldError 单个字段的绑定/校验错误
+type FieldError struct {
+	Field   string `json:"field"`           // 字段名（优先取json/form/uri标签）
+	Rule    string `json:"rule"`            // 未通过的规则 如：required、min
+	Param   string `json:"param,omitempty"` // 规则参数 如：min=3中的3
+	Message string `json:"message"`         // 可读的错误描述
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// BindingErrors 一次绑定产生的全部字段错误，可直接交给 Context.Fail 渲染
+type BindingErrors []FieldError
+
+func (es BindingErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Bind 先按uri标签绑定路径参数，再根据请求方法和Content-Type选择JSON请求体或表单（含查询参数），最后统一校验
+func (c *Context) Bind(obj any) error {
+	if len(c.Params) > 0 {
+		if err := decodeValues(obj, c.paramValues(), "uri"); err != nil {
+			return err
+		}
+	}
+	if c.Method != http.MethodGet && c.Method != http.MethodHead {
+		ct, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+		if ct == "application/json" {
+			if err := c.decodeJSON(obj); err != nil {
+				return err
+			}
+			return validate(obj)
+		}
+	}
+	if err := c.parseForm(); err != nil {
+		return err
+	}
+	return bindValues(obj, c.Req.Form, "form")
+}
+
+// BindJSON 将JSON请求体解码到结构体并校验
+func (c *Context) BindJSON(obj any) error {
+	if err := c.decodeJSON(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// BindForm 将表单和查询参数按form标签绑定到结构体并校验
+func (c *Context) BindForm(obj any) error {
+	if err := c.parseForm(); err != nil {
+		return err
+	}
+	return bindValues(obj, c.Req.Form, "form")
+}
+
+// BindQuery 将查询参数按form标签绑定到结构体并校验
+func (c *Context) BindQuery(obj any) error {
+	return bindValues(obj, c.Req.URL.Query(), "form")
+}
+
+// BindURI 将路径参数按uri标签绑定到结构体并校验
+func (c *Context) BindURI(obj any) error {
+	return bindValues(obj, c.paramValues(), "uri")
+}
+
+func (c *Context) decodeJSON(obj any) error {
+	if c.Req.Body == nil {
+		return errors.New("invalid request: empty body")
+	}
+	return json.NewDecoder(c.Req.Body).Decode(obj)
+}
+
+func (c *Context) parseForm() error {
+	if err := c.Req.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	return nil
+}
+
+func (c *Context) paramValues() url.Values {
+	values := make(url.Values, len(c.Params))
+	for _, p := range c.Params {
+		values.Set(p.Key, p.Value)
+	}
+	return values
+}
+
+func bindValues(obj any, values url.Values, tag string) error {
+	if err := decodeValues(obj, values, tag); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// 只解码不校验，供需要合并多个来源的 Bind 使用
+func decodeValues(obj any, values url.Values, tag string) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binding: expected a non-nil pointer to struct, got %T", obj)
+	}
+
+	var errs BindingErrors
+	decodeStruct(rv.Elem(), values, tag, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 按标签将values中的值写入结构体字段，字段名和展开规则见 BindingField
+func decodeStruct(rv reflect.Value, values url.Values, tag string, errs *BindingErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		fv := rv.Field(i)
+		name, inline := BindingField(rt.Field(i), tag)
+		if inline {
+			decodeStruct(fv, values, tag, errs)
+			continue
+		}
+		if name == "" {
+			continue
+		}
+
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if err := setField(fv, vs); err != nil {
+			*errs = append(*errs, FieldError{
+				Field:   name,
+				Rule:    "type",
+				Param:   fv.Type().String(),
+				Message: fmt.Sprintf("%s: cannot parse %q as %s", name, vs[0], fv.Type()),
+			})
+		}
+	}
+}
+
+func setField(fv reflect.Value, vs []string) error {
+	switch fv.Kind() {
+	case reflect.Pointer:
+		v := reflect.New(fv.Type().Elem())
+		if err := setField(v.Elem(), vs); err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	default:
+		return setValue(fv, vs[0])
+	}
+}
+
+func setValue(fv reflect.Value, s string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported kind %s", fv.Kind())
+	}
+	return nil
+}
+
+// 按结构体类型缓存解析后的校验规则，正则只编译一次
+var rulesCache sync.Map // map[reflect.Type]*structRules
+
+type structRules struct {
+	fields [][]bindingRule // 按字段下标保存的规则
+	err    error           // 规则有误时的错误
+}
+
+// 解析后的单条规则
+type bindingRule struct {
+	tag   string         // 规则名 如：min
+	param string         // 规则参数 如：3
+	limit float64        // min、max、len的参数
+	re    *regexp.Regexp // regexp的参数
+}
+
+// 根据binding标签校验结构体，支持的规则：required、omitempty、min、max、len、regexp
+// 规则之间用逗号分隔，regexp需放在最后，如：`binding:"required,min=3,regexp=^[a-z]+$"`
+// omitempty表示字段可选，为零值时跳过其后的规则，如：`binding:"omitempty,min=18"`
+// 标签本身有误（未知规则、参数非法）时返回普通错误而不是 BindingErrors
+func validate(obj any) error {
+	rv := reflect.ValueOf(obj)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs BindingErrors
+	if err := validateStruct(rv, &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, errs *BindingErrors) error {
+	rt := rv.Type()
+	rules := rulesOf(rt)
+	if rules.err != nil {
+		return rules.err
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		// 未导出类型的匿名字段也会被展开绑定，同样需要校验
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+
+		fv := rv.Field(i)
+		for _, rule := range rules.fields[i] {
+			if rule.tag == "omitempty" {
+				if isEmpty(fv) {
+					break
+				}
+				continue
+			}
+			if e, ok := checkRule(fieldName(sf), fv, rule); !ok {
+				*errs = append(*errs, e)
+				break
+			}
+		}
+
+		// 递归校验嵌套结构体
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			if err := validateStruct(fv, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 读取或解析结构体的校验规则
+func rulesOf(rt reflect.Type) *structRules {
+	if v, ok := rulesCache.Load(rt); ok {
+		return v.(*structRules)
+	}
+	rules := &structRules{fields: make([][]bindingRule, rt.NumField())}
+	for i := 0; i < rt.NumField() && rules.err == nil; i++ {
+		sf := rt.Field(i)
+		tag, ok := sf.Tag.Lookup("binding")
+		if !sf.IsExported() || !ok {
+			continue
+		}
+		for _, rule := range SplitBindingRules(tag) {
+			r, err := parseRule(rule, sf.Type)
+			if err != nil {
+				rules.err = fmt.Errorf("gee: invalid binding rule %q on %s.%s: %v", rule, rt.Name(), sf.Name, err)
+				break
+			}
+			rules.fields[i] = append(rules.fields[i], r)
+		}
+	}
+	v, _ := rulesCache.LoadOrStore(rt, rules)
+	return v.(*structRules)
+}
+
+// 解析单条规则，ft为字段类型，规则与字段类型不符时返回错误
+func parseRule(rule string, ft reflect.Type) (bindingRule, error) {
+	tag, param, _ := strings.Cut(rule, "=")
+	r := bindingRule{tag: tag, param: param}
+	var err error
+	switch tag {
+	case "required", "omitempty":
+	case "min", "max", "len":
+		r.limit, err = strconv.ParseFloat(param, 64)
+	case "regexp":
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.String {
+			return r, fmt.Errorf("regexp requires a string field, got %s", ft)
+		}
+		r.re, err = regexp.Compile(param)
+	default:
+		err = errors.New("unknown rule")
+	}
+	return r, err
+}
+
+// 错误中展示的字段名
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// BindingField 返回字段按tag绑定时使用的名称，name为空时字段不参与绑定，inline为true时需要展开绑定其中的字段
+// 无标签的字段使用字段名；json与encoding/json一致，只展开无标签的匿名结构体，form、uri等展开所有无标签的结构体字段
+func BindingField(sf reflect.StructField, tag string) (name string, inline bool) {
+	name, ok := sf.Tag.Lookup(tag)
+	name, _, _ = strings.Cut(name, ",")
+	if name == "-" {
+		return "", false
+	}
+	if !ok {
+		ft := sf.Type
+		if tag == "json" {
+			// 与encoding/json一致，未导出类型的匿名指针不展开
+			for sf.Anonymous && ft.Kind() == reflect.Pointer && sf.IsExported() {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && ft.Kind() == reflect.Struct {
+				return "", true
+			}
+		} else if ft.Kind() == reflect.Struct && (sf.IsExported() || sf.Anonymous) {
+			return "", true
+		}
+	}
+	if !sf.IsExported() {
+		return "", false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, false
+}
+
+// SplitBindingRules 拆分binding标签中的规则，regexp的参数中可能含有逗号，因此取剩余的全部内容
+func SplitBindingRules(rules string) []string {
+	var result []string
+	for rules != "" {
+		if strings.HasPrefix(rules, "regexp=") {
+			return append(result, rules)
+		}
+		var rule string
+		rule, rules, _ = strings.Cut(rules, ",")
+		if rule != "" {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+func checkRule(name string, fv reflect.Value, rule bindingRule) (FieldError, bool) {
+	e := FieldError{Field: name, Rule: rule.tag, Param: rule.param}
+
+	switch rule.tag {
+	case "required":
+		e.Message = fmt.Sprintf("%s is required", name)
+		return e, !isEmpty(fv)
+	case "min", "max", "len":
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				return e, true
+			}
+			fv = fv.Elem()
+		}
+		size, unit := measure(fv)
+		switch rule.tag {
+		case "min":
+			e.Message = fmt.Sprintf("%s must be at least %s%s", name, rule.param, unit)
+			return e, size >= rule.limit
+		case "max":
+			e.Message = fmt.Sprintf("%s must be at most %s%s", name, rule.param, unit)
+			return e, size <= rule.limit
+		default:
+			e.Message = fmt.Sprintf("%s must be exactly %s%s", name, rule.param, unit)
+			return e, size == rule.limit
+		}
+	default: // regexp
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				return e, true
+			}
+			fv = fv.Elem()
+		}
+		e.Message = fmt.Sprintf("%s must match %s", name, rule.param)
+		return e, rule.re.MatchString(fv.String())
+	}
+}
+
+// 零值以及长度为0的字符串、切片、映射视为空
+func isEmpty(fv reflect.Value) bool {
+	return fv.IsZero() || hasLen(fv) && fv.Len() == 0
+}
+
+func hasLen(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// 数值取其值，字符串/切片/映射取其长度
+func measure(fv reflect.Value) (float64, string) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), ""
+	case reflect.String:
+		return float64(len([]rune(fv.String()))), " characters"
+	default:
+		if hasLen(fv) {
+			return float64(fv.Len()), " items"
+		}
+	}
+	return 0, ""
+}
@@ -2,45 +2,209 @@ package gee
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 type H map[string]any
 
+// 中止后的中间件索引，Next循环会直接退出
+const abortIndex = math.MaxInt / 2
+
+// Errors 处理过程中收集的错误
+type Errors []error
+
+// Last 最后一个错误
+func (es Errors) Last() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es[len(es)-1]
+}
+
+func (es Errors) String() string {
+	msgs := make([]string, 0, len(es))
+	for _, err := range es {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
 // Context 一次http请求的上下文
+// Context 由引擎的对象池复用，处理器返回后不能继续持有，需要在goroutine中使用时请调用Copy
 type Context struct {
-	Writer     http.ResponseWriter // 响应抽象
-	Req        *http.Request       // 请求抽象
-	Path       string              // 路径
-	Method     string              // 方法
-	Params     map[string]string   // 参数
-	StatusCode int                 // 状态码
-	handlers   []HandlerFunc       // 处理器/中间件
-	index      int                 // 当前执行到的中间件索引
-	engine     *Engine             // 所属引擎
+	Writer ResponseWriter // 响应抽象
+	Req    *http.Request  // 请求抽象
+	Path   string         // 路径
+	Method string         // 方法
+	Params Params         // 参数
+	Keys   map[string]any // 中间件与处理器之间传递的键值对
+	Errors Errors         // 处理过程中收集的错误
+
+	// StatusCode 通过 Status 设置的状态码
+	//
+	// Deprecated: 直接调用 c.Writer.WriteHeader 时不会同步，请使用 c.Writer.Status()
+	StatusCode int
+
+	mu        sync.RWMutex   // 保护Keys
+	writermem responseWriter // Writer的底层实现，随Context一起复用
+	handlers  []HandlerFunc  // 处理器/中间件
+	index     int            // 当前执行到的中间件索引
+	engine    *Engine        // 所属引擎
+}
+
+// 重置上下文以便复用，保留参数和处理器切片已分配的容量
+func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = req
+	c.Path = req.URL.Path
+	c.Method = req.Method
+	c.Params = c.Params[:0]
+	c.StatusCode = 0
+	c.Keys = nil
+	c.Errors = c.Errors[:0]
+	c.handlers = c.handlers[:0]
+	c.index = -1
+}
+
+// NewContext 创建不经过路由、不放回对象池的Context，用于单独测试处理器
+func (e *Engine) NewContext(w http.ResponseWriter, req *http.Request) *Context {
+	c := &Context{engine: e}
+	c.reset(w, req)
+	return c
 }
 
-func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	return &Context{
-		Writer: w,
-		Req:    req,
-		Path:   req.URL.Path,
-		Method: req.Method,
-		index:  -1,
+// Copy 返回可以在请求结束后安全使用的只读副本
+// 副本的Writer不关联原始连接，写入响应体会返回 ErrDetachedWriter
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Req:        c.Req,
+		Path:       c.Path,
+		Method:     c.Method,
+		Params:     append(Params(nil), c.Params...),
+		StatusCode: c.StatusCode,
+		Errors:     append(Errors(nil), c.Errors...),
+		index:      abortIndex,
+		engine:     c.engine,
 	}
+	cp.writermem.reset(&detachedWriter{header: make(http.Header)})
+	cp.writermem.status = c.Writer.Status()
+	cp.Writer = &cp.writermem
+
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]any, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
 }
 
 func (c *Context) Next() {
 	c.index++
-	for s := len(c.handlers); c.index < s; c.index++ {
+	for c.index < len(c.handlers) {
 		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort 跳过后续尚未执行的处理器，已经执行的中间件中c.Next()之后的逻辑仍会执行
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted 是否已中止
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus 立即发送状态码并中止
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+// AbortWithStatusJSON 响应JSON并中止
+func (c *Context) AbortWithStatusJSON(code int, obj any) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// AbortWithError 发送状态码、记录错误并中止
+func (c *Context) AbortWithError(code int, err error) error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
+}
+
+// Error 收集错误，通常由最后执行的错误处理中间件统一渲染
+func (c *Context) Error(err error) error {
+	if err == nil {
+		panic("gee: err is nil")
+	}
+	c.Errors = append(c.Errors, err)
+	return err
+}
+
+// Set 保存键值对，Keys在首次调用时才初始化
+func (c *Context) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]any)
+	}
+	c.Keys[key] = value
+}
+
+// Get 获取键值对
+func (c *Context) Get(key string) (value any, exists bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists = c.Keys[key]
+	return
+}
+
+// MustGet 获取键值对，不存在时panic
+func (c *Context) MustGet(key string) any {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("gee: key \"" + key + "\" does not exist")
+}
+
+// GetString 获取字符串类型的值，不存在或类型不符时返回零值
+func (c *Context) GetString(key string) (s string) {
+	if value, ok := c.Get(key); ok {
+		s, _ = value.(string)
+	}
+	return
+}
+
+// GetInt 获取int类型的值，不存在或类型不符时返回零值
+func (c *Context) GetInt(key string) (i int) {
+	if value, ok := c.Get(key); ok {
+		i, _ = value.(int)
 	}
+	return
+}
+
+// GetBool 获取bool类型的值，不存在或类型不符时返回零值
+func (c *Context) GetBool(key string) (b bool) {
+	if value, ok := c.Get(key); ok {
+		b, _ = value.(bool)
+	}
+	return
 }
 
 func (c *Context) Param(key string) string {
-	value, _ := c.Params[key]
-	return value
+	return c.Params.ByName(key)
 }
 
 func (c *Context) PostForm(key string) string {
@@ -51,15 +215,30 @@ func (c *Context) Query(key string) string {
 	return c.Req.URL.Query().Get(key)
 }
 
+// Status 设置状态码，响应头在写入响应体或请求处理结束时才发送
 func (c *Context) Status(code int) {
-	c.StatusCode = code
 	c.Writer.WriteHeader(code)
+	c.StatusCode = c.Writer.Status()
 }
 
 func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// Cookie 获取请求中名为name的cookie值，不存在时返回 http.ErrNoCookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+// SetCookie 添加Set-Cookie响应头，需要在写入响应体之前调用
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	http.SetCookie(c.Writer, cookie)
+}
+
 func (c *Context) String(code int, format string, values ...any) {
 	c.SetHeader("Content-Type", "text/plain")
 	c.Status(code)
@@ -76,7 +255,14 @@ func (c *Context) JSON(code int, obj any) {
 	}
 }
 
+// Fail 响应错误信息并跳过后续的处理器，绑定/校验错误会以JSON形式返回字段错误列表
 func (c *Context) Fail(code int, err error) {
+	c.Abort()
+	var errs BindingErrors
+	if errors.As(err, &errs) {
+		c.JSON(code, H{"message": "invalid request", "errors": errs})
+		return
+	}
 	http.Error(c.Writer, err.Error(), code)
 }
 
@@ -87,10 +273,21 @@ func (c *Context) Data(code int, data []byte) {
 	}
 }
 
+// HTML 使用请求路径所属分组的模板集合渲染，分组未加载模板时使用上层分组的
 func (c *Context) HTML(code int, name string, data any) {
+	h := c.engine.htmlRender(c.Req.URL.Path)
+	if h == nil {
+		c.Fail(http.StatusInternalServerError, errors.New("gee: html templates are not loaded"))
+		return
+	}
+	t, entry, err := h.lookup(name)
+	if err != nil {
+		c.Fail(http.StatusInternalServerError, err)
+		return
+	}
 	c.SetHeader("Content-Type", "text/html")
 	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
+	if err := t.ExecuteTemplate(c.Writer, entry, data); err != nil {
 		c.Fail(http.StatusInternalServerError, err)
 	}
 }
@@ -4,34 +4,62 @@ import (
 	"html/template"
 	"log"
 	"net/http"
-	"path"
 	"strings"
+	"sync"
+	"time"
 )
 
 type HandlerFunc func(c *Context)
 
+// Any 注册时覆盖的请求方法
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
 // RouterGroup 路由分组
 type RouterGroup struct {
 	prefix      string        // 前缀
 	middlewares []HandlerFunc // 中间件
 	parent      *RouterGroup  // 父分组
 	engine      *Engine       // 引擎
+	html        *htmlRender   // 分组的模板集合
 }
 
 // Engine web引擎
 type Engine struct {
-	*RouterGroup                     // Engine是最顶层的分组
-	router        *router            // 路由器
-	groups        []*RouterGroup     // 管理全部分组
-	htmlTemplates *template.Template // 将所有的模板加载进内存
-	funcMap       template.FuncMap   // 所有的自定义模板渲染函数
+	*RouterGroup                       // Engine是最顶层的分组
+	router       *router               // 路由器
+	groups       []*RouterGroup        // 管理全部分组
+	funcMap      template.FuncMap      // 所有的自定义模板渲染函数
+	noRoute      []HandlerFunc         // 路由未匹配时的处理器
+	noMethod     []HandlerFunc         // 请求方法不匹配时的处理器
+	pre          []HandlerFunc         // 路由匹配之前执行的中间件
+	routes       []*RouteInfo          // 按注册顺序记录的全部路由
+	namedRoutes  map[string]*RouteInfo // 命名路由
+	pool         sync.Pool             // 上下文对象池
+	mu           sync.Mutex            // 保护servers和wsConns
+	servers      []*http.Server        // 已启动的http服务
+	wsConns      map[*WSConn]struct{}  // 活跃的WebSocket连接
+
+	ReadTimeout  time.Duration // 读取整个请求的超时时间
+	WriteTimeout time.Duration // 写响应的超时时间
+	IdleTimeout  time.Duration // keep-alive连接的空闲超时时间
+	UseH2C       bool          // 是否支持明文HTTP/2（h2c）
 }
 
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
+	engine := &Engine{
+		router:   newRouter(),
+		noRoute:  []HandlerFunc{notFound},
+		noMethod: []HandlerFunc{methodNotAllowed},
+	}
 	// Engine作为最顶层的分组，拥有RouterGroup所有的能力
 	engine.RouterGroup = &RouterGroup{engine: engine}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine.pool.New = func() any {
+		return &Context{Params: make(Params, 0, engine.router.maxParams), engine: engine}
+	}
 	return engine
 }
 
@@ -41,45 +69,50 @@ func Default() *Engine {
 	return engine
 }
 
-// SetFuncMap 设置自定义渲染函数
-func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
-	e.funcMap = funcMap
-}
-
-// LoadHTMLGlob 加载模板
-func (e *Engine) LoadHTMLGlob(pattern string) {
-	e.htmlTemplates = template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
+// NoRoute 设置路由未匹配时的处理器，默认响应404
+func (e *Engine) NoRoute(handlers ...HandlerFunc) {
+	e.noRoute = handlers
 }
 
-func (e *Engine) addRoute(method string, pattern string, handler HandlerFunc) {
-	e.router.addRoute(method, pattern, handler)
+// NoMethod 设置路径存在但请求方法不匹配时的处理器，默认响应405
+// 处理器执行前已经设置好Allow响应头
+func (e *Engine) NoMethod(handlers ...HandlerFunc) {
+	e.noMethod = handlers
 }
 
-func (e *Engine) GET(pattern string, handler HandlerFunc) {
-	e.addRoute("GET", pattern, handler)
+// Pre 注册在路由匹配之前执行的中间件（如CORS预检），任一中间件中止或写出响应后不再进行路由
+func (e *Engine) Pre(middlewares ...HandlerFunc) {
+	e.pre = append(e.pre, middlewares...)
 }
 
-func (e *Engine) POST(pattern string, handler HandlerFunc) {
-	e.addRoute("POST", pattern, handler)
+// SetFuncMap 设置自定义渲染函数，需要在加载模板之前调用
+func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
+	e.funcMap = funcMap
 }
 
-func (e *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, e)
+// LoadHTMLGlob 加载模板
+func (e *Engine) LoadHTMLGlob(pattern string) {
+	e.LoadHTML(HTMLConfig{Layouts: []string{pattern}})
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandlerFunc
-	for _, group := range e.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
+	c := e.pool.Get().(*Context)
+	c.reset(w, req)
+	defer e.pool.Put(c)
+
+	if len(e.pre) > 0 {
+		c.handlers = append(c.handlers, e.pre...)
+		c.Next()
+		if c.IsAborted() || c.Writer.Written() {
+			return
 		}
+		c.handlers = c.handlers[:0]
+		c.index = -1
 	}
 
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = e
-
 	e.router.handle(c)
+	// 处理器只设置了状态码而没有写入响应体时，在这里发送响应头
+	c.Writer.WriteHeaderNow()
 }
 
 // Group 新增分组
@@ -94,39 +127,108 @@ func (g *RouterGroup) Group(prefix string) *RouterGroup {
 	return newGroup
 }
 
+// Use 注册分组中间件，中间件在注册路由时合并进路由的处理链，只对之后注册的路由生效
 func (g *RouterGroup) Use(middlewares ...HandlerFunc) {
 	g.middlewares = append(g.middlewares, middlewares...)
 }
 
-func (g *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) {
-	pattern := g.prefix + comp
-	log.Printf("Route %4s - %s", method, pattern)
-	g.engine.router.addRoute(method, pattern, handler)
+// 从最顶层分组到当前分组依次收集中间件，再追加handlers
+func (g *RouterGroup) combineHandlers(handlers []HandlerFunc) []HandlerFunc {
+	var groups []*RouterGroup
+	size := len(handlers)
+	for p := g; p != nil; p = p.parent {
+		groups = append(groups, p)
+		size += len(p.middlewares)
+	}
+
+	merged := make([]HandlerFunc, 0, size)
+	for i := len(groups) - 1; i >= 0; i-- {
+		merged = append(merged, groups[i].middlewares...)
+	}
+	return append(merged, handlers...)
 }
 
-func (g *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	g.addRoute("GET", pattern, handler)
+// 查找路径所属的最深的分组，前缀只在完整的路径片段上匹配，如 /v1 不匹配 /v10
+func (e *Engine) matchGroup(path string) *RouterGroup {
+	group := e.RouterGroup
+	for _, g := range e.groups {
+		if len(g.prefix) > len(group.prefix) && hasPathPrefix(path, g.prefix) {
+			group = g
+		}
+	}
+	return group
 }
 
-func (g *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	g.addRoute("POST", pattern, handler)
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || prefix == "" || prefix[len(prefix)-1] == '/' || path[len(prefix)] == '/'
 }
 
-func (g *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	absolutePath := path.Join(g.prefix, relativePath)
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(c *Context) {
-		file := c.Param("filepath")
-		if _, err := fs.Open(file); err != nil {
-			c.Status(http.StatusNotFound)
-			return
+// 注册路由，handlers中最后一个为处理器，其余为只作用于该路由的中间件
+func (g *RouterGroup) addRoute(method string, comp string, handlers []HandlerFunc) *RouteInfo {
+	if len(handlers) == 0 {
+		panic("gee: there must be at least one handler")
+	}
+	pattern := g.prefix + comp
+	log.Printf("Route %4s - %s", method, pattern)
+	g.engine.router.addRoute(method, pattern, g.combineHandlers(handlers))
+
+	handler := handlers[len(handlers)-1]
+	route := &RouteInfo{
+		Method:      method,
+		Path:        pattern,
+		Handler:     nameOfFunction(handler),
+		HandlerFunc: handler,
+		engine:      g.engine,
+	}
+	g.engine.routes = append(g.engine.routes, route)
+	return route
+}
+
+// Handle 按指定的请求方法注册路由
+func (g *RouterGroup) Handle(method string, pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(method, pattern, handlers)
+}
+
+// Any 为所有标准请求方法注册同一个处理器，返回GET路由的信息以便命名
+func (g *RouterGroup) Any(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	var route *RouteInfo
+	for _, method := range anyMethods {
+		if r := g.addRoute(method, pattern, handlers); method == http.MethodGet {
+			route = r
 		}
-		fileServer.ServeHTTP(c.Writer, c.Req)
 	}
+	return route
+}
+
+func (g *RouterGroup) GET(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(http.MethodGet, pattern, handlers)
+}
+
+func (g *RouterGroup) POST(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(http.MethodPost, pattern, handlers)
+}
+
+func (g *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(http.MethodPut, pattern, handlers)
+}
+
+func (g *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(http.MethodPatch, pattern, handlers)
+}
+
+func (g *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(http.MethodDelete, pattern, handlers)
+}
+
+// HEAD 未注册HEAD路由时，HEAD请求会自动交给对应的GET处理器
+func (g *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(http.MethodHead, pattern, handlers)
 }
 
-func (g *RouterGroup) Static(relativePath string, root string) {
-	handler := g.createStaticHandler(relativePath, http.Dir(root))
-	urlPattern := path.Join(relativePath, "/*filepath")
-	g.GET(urlPattern, handler)
+// OPTIONS 未注册OPTIONS路由时，会根据已注册的方法自动响应Allow头
+func (g *RouterGroup) OPTIONS(pattern string, handlers ...HandlerFunc) *RouteInfo {
+	return g.addRoute(http.MethodOptions, pattern, handlers)
 }
@@ -1,10 +1,25 @@
 package gee
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"html/template"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -39,11 +54,1062 @@ func TestGetRoute(t *testing.T) {
 		t.Fatal("should match /hello/:name")
 	}
 
-	if ps["name"] != "geektutu" {
+	if ps.ByName("name") != "geektutu" {
 		t.Fatal("name should be equal to 'geektutu'")
 	}
 
-	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps["name"])
+	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps.ByName("name"))
+}
+
+func TestRadixRoute(t *testing.T) {
+	r := newRouter()
+	for _, pattern := range []string{"/help", "/hello", "/hello/:name/profile", "/he", "/src/*filepath", "/users/:id/books/:book"} {
+		r.addRoute("GET", pattern, nil)
+	}
+
+	cases := []struct {
+		path, pattern string
+		params        Params
+	}{
+		{"/he", "/he", Params{}},
+		{"/help/", "/help", Params{}},
+		{"/hello", "/hello", Params{}},
+		{"/hello/gee/profile", "/hello/:name/profile", Params{{"name", "gee"}}},
+		{"/src/css/main.css", "/src/*filepath", Params{{"filepath", "css/main.css"}}},
+		{"/users/1/books/2", "/users/:id/books/:book", Params{{"id", "1"}, {"book", "2"}}},
+		{"/hel", "", nil},
+		{"/hello/gee", "", nil},
+		{"/src", "", nil},
+	}
+	for _, tc := range cases {
+		n, ps := r.getRoute("GET", tc.path)
+		if tc.pattern == "" {
+			if n != nil {
+				t.Fatalf("%s should not match, got %s", tc.path, n.pattern)
+			}
+			continue
+		}
+		if n == nil || n.pattern != tc.pattern || !reflect.DeepEqual(ps, tc.params) {
+			t.Fatalf("%s should match %s with %v, got %v %v", tc.path, tc.pattern, tc.params, n, ps)
+		}
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	patterns := []string{"/p/*filepath", "/p/:lang/doc", "/p/:lang", "/p/go/doc", "/p/go"}
+	cases := map[string]string{
+		"/p/go":        "/p/go",
+		"/p/go/doc":    "/p/go/doc",
+		"/p/c":         "/p/:lang",
+		"/p/c/doc":     "/p/:lang/doc",
+		"/p/go/intro":  "/p/*filepath",
+		"/p/c/doc/faq": "/p/*filepath",
+	}
+
+	// 正序和逆序注册的匹配结果应当一致
+	for _, reverse := range []bool{false, true} {
+		r := newRouter()
+		for i := range patterns {
+			if reverse {
+				i = len(patterns) - 1 - i
+			}
+			r.addRoute("GET", patterns[i], nil)
+		}
+		for path, expect := range cases {
+			if n, _ := r.getRoute("GET", path); n == nil || n.pattern != expect {
+				t.Fatalf("%s should match %s (reverse=%v)", path, expect, reverse)
+			}
+		}
+	}
+}
+
+func TestRouteConflict(t *testing.T) {
+	conflicts := [][2]string{
+		{"/p/:lang", "/p/:name"},
+		{"/p/:lang/doc", "/p/:name/intro"},
+		{"/static/*filepath", "/static/*path"},
+		{"/hello", "/hello/"},
+	}
+	for _, tc := range conflicts {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("registering %s after %s should panic", tc[1], tc[0])
+				}
+			}()
+			r := newRouter()
+			r.addRoute("GET", tc[0], nil)
+			r.addRoute("GET", tc[1], nil)
+		}()
+	}
+}
+
+func TestMethods(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "get %s", c.Param("id"))
+	})
+	r.PUT("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "put %s", c.Param("id"))
+	})
+	r.Any("/any", func(c *Context) {
+		c.String(http.StatusOK, c.Method)
+	})
+
+	cases := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{http.MethodGet, "/users/1", http.StatusOK, "get 1"},
+		{http.MethodPut, "/users/2", http.StatusOK, "put 2"},
+		{http.MethodHead, "/users/3", http.StatusOK, ""},
+		{http.MethodPatch, "/any", http.StatusOK, http.MethodPatch},
+		{http.MethodDelete, "/any", http.StatusOK, http.MethodDelete},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.code || (tc.method != http.MethodHead && w.Body.String() != tc.body) {
+			t.Fatalf("%s %s: got %d %q", tc.method, tc.path, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/users/1", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, HEAD, OPTIONS, PUT" {
+		t.Fatalf("OPTIONS: got %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestNoRouteNoMethod(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.SetHeader("X-Middleware", "1")
+		c.Next()
+	})
+	r.GET("/users", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Fatalf("expected 405 with Allow header, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	r.NoRoute(func(c *Context) {
+		c.JSON(http.StatusNotFound, H{"error": "not found"})
+	})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("X-Middleware") != "1" ||
+		w.Body.String() != "{\"error\":\"not found\"}\n" {
+		t.Fatalf("custom NoRoute failed: %d %q", w.Code, w.Body.String())
+	}
+}
+
+type userURI struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+type createUser struct {
+	Name  string   `json:"name" form:"name" binding:"required,min=2,max=10"`
+	Code  string   `json:"code" form:"code" binding:"len=4,regexp=^[A-Z]{2},?[0-9]+$"`
+	Tags  []string `json:"tags" form:"tags" binding:"max=2"`
+	Admin bool     `json:"admin" form:"admin"`
+}
+
+type updateUser struct {
+	ID   int    `uri:"id" json:"-" binding:"required,min=1"`
+	Name string `json:"name" binding:"required"`
+}
+
+type badRule struct {
+	Code string `form:"code" binding:"regexp=[a-"`
+}
+
+type badRuleKind struct {
+	Code int `form:"code" binding:"regexp=^[0-9]+$"`
+}
+
+type pageQuery struct {
+	Page int `form:"page" binding:"min=1"`
+}
+
+type filterQuery struct {
+	Status string `form:"status" binding:"required"`
+}
+
+type searchQuery struct {
+	pageQuery
+	Filter filterQuery
+}
+
+type optionalQuery struct {
+	Age  int    `form:"age" binding:"omitempty,min=18"`
+	Code string `form:"code" binding:"omitempty,regexp=^[a-z]+$"`
+}
+
+func TestBindSources(t *testing.T) {
+	r := New()
+	r.PUT("/users/:id", func(c *Context) {
+		var u updateUser
+		if err := c.Bind(&u); err != nil {
+			c.Fail(http.StatusBadRequest, err)
+			return
+		}
+		c.String(http.StatusOK, "%d %s", u.ID, u.Name)
+	})
+	r.GET("/bad", func(c *Context) {
+		var b badRule
+		err := c.BindQuery(&b)
+		_, isFieldErr := err.(BindingErrors)
+		c.String(http.StatusOK, "%v %v", err != nil, isFieldErr)
+	})
+	r.GET("/bad-kind", func(c *Context) {
+		var b badRuleKind
+		err := c.BindQuery(&b)
+		_, isFieldErr := err.(BindingErrors)
+		c.String(http.StatusOK, "%v %v", err != nil, isFieldErr)
+	})
+	r.GET("/search", func(c *Context) {
+		var q searchQuery
+		if err := c.BindQuery(&q); err != nil {
+			c.Fail(http.StatusBadRequest, err)
+			return
+		}
+		c.String(http.StatusOK, "%d %s", q.Page, q.Filter.Status)
+	})
+	r.GET("/optional", func(c *Context) {
+		var q optionalQuery
+		if err := c.BindQuery(&q); err != nil {
+			c.Fail(http.StatusBadRequest, err)
+			return
+		}
+		c.String(http.StatusOK, "%d %s", q.Age, q.Code)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/users/7", strings.NewReader(`{"name":"gee"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "7 gee" {
+		t.Fatalf("Bind should merge path params and body, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPut, "/users/0", strings.NewReader(`{"name":"gee"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"id"`) {
+		t.Fatalf("path params should be validated, got %d %s", w.Code, w.Body.String())
+	}
+
+	// 标签有误（包括regexp用于非字符串字段）时返回错误而不是panic
+	for _, path := range []string{"/bad?code=x", "/bad?code=x", "/bad-kind?code=1"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != "true false" {
+			t.Fatalf("%s: invalid rule should be reported as a plain error, got %q", path, w.Body.String())
+		}
+	}
+
+	// 匿名和具名的无标签结构体都会展开绑定和校验
+	w = httptest.NewRecorder()
+	if r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?page=2&status=on", nil)); w.Body.String() != "2 on" {
+		t.Fatalf("nested structs should be bound, got %q", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	if r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?page=0", nil)); w.Code != http.StatusBadRequest ||
+		!strings.Contains(w.Body.String(), `"field":"page"`) || !strings.Contains(w.Body.String(), `"field":"status"`) {
+		t.Fatalf("nested structs should be validated, got %d %s", w.Code, w.Body.String())
+	}
+
+	// omitempty的字段缺省时不校验，传入时仍需满足规则
+	for path, want := range map[string]int{
+		"/optional":                 http.StatusOK,
+		"/optional?age=20&code=gee": http.StatusOK,
+		"/optional?age=3":           http.StatusBadRequest,
+		"/optional?code=GEE":        http.StatusBadRequest,
+	} {
+		w = httptest.NewRecorder()
+		if r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil)); w.Code != want {
+			t.Fatalf("%s: expected %d, got %d %s", path, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	r := New()
+	r.POST("/users/:id", func(c *Context) {
+		var p userURI
+		if err := c.BindURI(&p); err != nil {
+			c.Fail(http.StatusBadRequest, err)
+			return
+		}
+		var u createUser
+		if err := c.Bind(&u); err != nil {
+			c.Fail(http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, H{"id": p.ID, "user": u})
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users/7", strings.NewReader(`{"name":"gee","code":"AB12","tags":["a"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":7,"user":{"name":"gee","code":"AB12","tags":["a"],"admin":false}}`+"\n" {
+		t.Fatalf("bind json failed: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/users/7?tags=a&tags=b&tags=c", strings.NewReader("name=g&code=ab12&admin=yes"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(w, req)
+	var resp struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusBadRequest {
+		t.Fatalf("expected field errors, got %d %s", w.Code, w.Body.String())
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Field != "admin" || resp.Errors[0].Rule != "type" {
+		t.Fatalf("unexpected decode errors: %+v", resp.Errors)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/users/7?tags=a&tags=b&tags=c", strings.NewReader("name=g&code=ab12"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(w, req)
+	resp.Errors = nil
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	var rules []string
+	for _, e := range resp.Errors {
+		rules = append(rules, e.Field+":"+e.Rule)
+	}
+	if !reflect.DeepEqual(rules, []string{"name:min", "code:regexp", "tags:max"}) {
+		t.Fatalf("unexpected validation errors: %v", rules)
+	}
+}
+
+func TestShutdownBlockedWS(t *testing.T) {
+	r := New()
+	// 对端从不读取，关闭帧的写入会一直阻塞
+	server, client := net.Pipe()
+	defer client.Close()
+	ws := &WSConn{conn: server}
+	r.trackWS(ws, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- r.Shutdown(ctx) }()
+
+	// 关闭期间仍可以登记和注销连接
+	time.Sleep(20 * time.Millisecond)
+	tracked := make(chan struct{})
+	go func() {
+		r.trackWS(ws, false)
+		close(tracked)
+	}()
+	select {
+	case <-tracked:
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("trackWS should not wait for close frames")
+	}
+	if err := <-done; err != nil || time.Since(start) > time.Second {
+		t.Fatalf("Shutdown should give up after the deadline, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	r := New()
+	started := make(chan struct{})
+	r.GET("/slow", func(c *Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- r.RunListener(l) }()
+
+	resp := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			resp <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		resp <- string(body)
+	}()
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-resp; body != "done" {
+		t.Fatalf("in-flight request should be drained, got %q", body)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("RunListener should return nil after Shutdown, got %v", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	r := New()
+	disconnected := make(chan bool, 1)
+	r.GET("/events", func(c *Context) {
+		n := 0
+		disconnected <- c.Stream(func(w io.Writer) bool {
+			if n == 0 {
+				c.SSEComment("keep-alive")
+			}
+			n++
+			c.SSEvent("progress", H{"done": n})
+			return n < 2
+		})
+		c.SSEvent("", "finished\nok")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	expect := ": keep-alive\n\n" +
+		"event: progress\ndata: {\"done\":1}\n\n" +
+		"event: progress\ndata: {\"done\":2}\n\n" +
+		"data: finished\ndata: ok\n\n"
+	if w.Body.String() != expect || w.Header().Get("Content-Type") != "text/event-stream" || !w.Flushed {
+		t.Fatalf("unexpected event stream: %q", w.Body.String())
+	}
+	if <-disconnected {
+		t.Fatal("stream should end normally")
+	}
+
+	// 客户端断开后不再调用step
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+	if !<-disconnected || strings.Contains(w.Body.String(), "progress") {
+		t.Fatalf("stream should stop on disconnect, got %q", w.Body.String())
+	}
+
+	// 事件名和数据中的换行不能注入额外的字段
+	w = httptest.NewRecorder()
+	c := r.NewContext(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	c.SSEvent("a\r\nid: 1", "x\ry\r\nz")
+	c.SSEComment("c\nretry: 0")
+	if body := w.Body.String(); body != "event: aid: 1\ndata: x\ndata: y\ndata: z\n\n: c\n: retry: 0\n\n" {
+		t.Fatalf("line breaks should be escaped, got %q", body)
+	}
+}
+
+func TestSSEStream(t *testing.T) {
+	r := New()
+	events := make(chan SSE)
+	disconnected := make(chan bool, 1)
+	r.GET("/events", func(c *Context) {
+		disconnected <- c.SSEStream(events, 10*time.Millisecond)
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var sb strings.Builder
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil || line == "\n" {
+				return sb.String()
+			}
+			sb.WriteString(line)
+		}
+	}
+
+	// 空闲时定时推送注释
+	if e := readEvent(); e != ": keep-alive\n" {
+		t.Fatalf("expected keep-alive comment, got %q", e)
+	}
+	events <- SSE{Event: "progress", Data: H{"done": 1}}
+	for e := readEvent(); e != "event: progress\ndata: {\"done\":1}\n"; e = readEvent() {
+		if e != ": keep-alive\n" {
+			t.Fatalf("unexpected event %q", e)
+		}
+	}
+
+	// 客户端断开后结束
+	cancel()
+	select {
+	case d := <-disconnected:
+		if !d {
+			t.Fatal("stream should end because of the disconnect")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream should stop when the client is gone")
+	}
+}
+
+// 测试用的最简WebSocket客户端帧读写
+func wsWriteFrame(w io.Writer, fin bool, opcode byte, payload []byte) {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{b0, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, c := range payload {
+		frame = append(frame, c^mask[i%4])
+	}
+	_, _ = w.Write(frame)
+}
+
+func wsReadFrame(r *bufio.Reader) (byte, []byte) {
+	var head [2]byte
+	_, _ = io.ReadFull(r, head[:])
+	payload := make([]byte, head[1]&0x7f)
+	_, _ = io.ReadFull(r, payload)
+	return head[0] & 0x0f, payload
+}
+
+func TestWebSocket(t *testing.T) {
+	r := New()
+	var middleware bool
+	r.Use(func(c *Context) {
+		middleware = true
+		c.Next()
+	})
+	r.WS("/echo/:room", func(ws *WSConn) {
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = ws.WriteMessage(mt, append([]byte(ws.Context().Param("room")+":"), data...))
+		}
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = io.WriteString(conn, "GET /echo/gee HTTP/1.1\r\nHost: "+srv.Listener.Addr().String()+"\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake failed: %v %v", resp, err)
+	}
+	if !middleware {
+		t.Fatal("middleware should run before upgrade")
+	}
+
+	// 分片的文本消息中间穿插ping
+	wsWriteFrame(conn, false, TextMessage, []byte("hel"))
+	wsWriteFrame(conn, true, PingMessage, []byte("p"))
+	wsWriteFrame(conn, true, continuationFrame, []byte("lo"))
+	if op, data := wsReadFrame(br); op != PongMessage || string(data) != "p" {
+		t.Fatalf("expected pong, got %d %q", op, data)
+	}
+	if op, data := wsReadFrame(br); op != TextMessage || string(data) != "gee:hello" {
+		t.Fatalf("expected echo, got %d %q", op, data)
+	}
+
+	wsWriteFrame(conn, true, CloseMessage, []byte{0x03, 0xe8})
+	if op, data := wsReadFrame(br); op != CloseMessage || !bytes.Equal(data, []byte{0x03, 0xe8}) {
+		t.Fatalf("expected close reply, got %d %v", op, data)
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	r := New()
+	var status, size, code int
+	r.Use(func(c *Context) {
+		c.Next()
+		status, size, code = c.Writer.Status(), c.Writer.Size(), c.StatusCode
+	}, Recovery())
+	r.GET("/created", func(c *Context) {
+		c.Status(http.StatusCreated)
+		c.Status(http.StatusAccepted)
+	})
+	r.GET("/partial", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/created", nil))
+	if w.Code != http.StatusAccepted || status != http.StatusAccepted || code != http.StatusAccepted || size != 0 {
+		t.Fatalf("status should be sent once at the end, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partial", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" || status != http.StatusOK || code != http.StatusOK || size != len("partial") {
+		t.Fatalf("recovery should not write after the response was sent, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestContextKeysAndAbort(t *testing.T) {
+	r := New()
+	var after []string
+	r.Use(func(c *Context) {
+		c.Next()
+		after = append(after, "outer")
+	})
+	auth := r.Group("/admin")
+	auth.Use(func(c *Context) {
+		if c.Query("token") != "secret" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, H{"error": "unauthorized"})
+			return
+		}
+		c.Set("user", "geektutu")
+		c.Set("uid", 7)
+		c.Next()
+	})
+	var handled bool
+	auth.GET("/profile", func(c *Context) {
+		handled = true
+		if _, ok := c.Get("missing"); ok {
+			t.Error("missing key should not exist")
+		}
+		c.String(http.StatusOK, "%s %d %v", c.MustGet("user"), c.GetInt("uid"), c.GetBool("uid"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/profile", nil))
+	if w.Code != http.StatusUnauthorized || handled || !reflect.DeepEqual(after, []string{"outer"}) {
+		t.Fatalf("abort should skip the handler but unwind middleware, got %d %v", w.Code, after)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/profile?token=secret", nil))
+	if w.Body.String() != "geektutu 7 false" || !handled {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	r := New()
+	copied := make(chan *Context, 1)
+	r.GET("/:id", func(c *Context) {
+		c.Set("user", "geektutu")
+		c.Status(http.StatusAccepted)
+		copied <- c.Copy()
+		c.String(http.StatusAccepted, "origin")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/7", nil))
+	cp := <-copied
+	if cp.Param("id") != "7" || cp.GetString("user") != "geektutu" || cp.Writer.Status() != http.StatusAccepted {
+		t.Fatalf("copy should keep request data, got %q %q %d", cp.Param("id"), cp.GetString("user"), cp.Writer.Status())
+	}
+
+	// 副本写入不能panic，也不能影响原始响应
+	cp.String(http.StatusOK, "copy")
+	if _, err := cp.Writer.Write([]byte("copy")); !errors.Is(err, ErrDetachedWriter) {
+		t.Fatalf("write through a copy should fail, got %v", err)
+	}
+	if w.Body.String() != "origin" || w.Code != http.StatusAccepted {
+		t.Fatalf("copy should not write to the original response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestContextErrors(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			c.JSON(http.StatusBadGateway, H{"error": c.Errors.String(), "last": c.Errors.Last().Error()})
+		}
+	})
+	r.GET("/", func(c *Context) {
+		_ = c.Error(fmt.Errorf("upstream a failed"))
+		_ = c.Error(fmt.Errorf("upstream b failed"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var body map[string]string
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusBadGateway || body["error"] != "upstream a failed; upstream b failed" || body["last"] != "upstream b failed" {
+		t.Fatalf("unexpected error response %d %q", w.Code, w.Body.String())
+	}
+
+	// 复用的Context不应残留上一个请求的错误和键值对
+	c := r.pool.Get().(*Context)
+	c.reset(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(c.Errors) != 0 || c.Keys != nil || c.IsAborted() {
+		t.Fatal("context should be reset")
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	type book struct {
+		Title string `json:"title" xml:"title" yaml:"title"`
+	}
+	r := New()
+	r.GET("/xml", func(c *Context) { c.XML(http.StatusOK, book{Title: "gee"}) })
+	r.GET("/yaml", func(c *Context) { c.YAML(http.StatusOK, book{Title: "gee"}) })
+	r.GET("/pb", func(c *Context) { c.ProtoBuf(http.StatusOK, wrapperspb.String("gee")) })
+	r.GET("/book", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{Offered: []string{MIMEJSON, MIMEXML, MIMEYAML}, Data: book{Title: "gee"}})
+	})
+
+	cases := []struct {
+		path, accept, contentType, body string
+	}{
+		{"/xml", "", MIMEXML, "<book><title>gee</title></book>"},
+		{"/yaml", "", MIMEYAML, "title: gee\n"},
+		{"/book", "", MIMEJSON, `{"title":"gee"}` + "\n"},
+		{"/book", "text/html, application/xml;q=0.9, */*;q=0.8", MIMEXML, "<book><title>gee</title></book>"},
+		{"/book", "application/*;q=0.5, application/yaml", MIMEYAML, "title: gee\n"},
+		{"/book", "application/json;q=0, */*", MIMEXML, "<book><title>gee</title></book>"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Header().Get("Content-Type") != tc.contentType || w.Body.String() != tc.body {
+			t.Fatalf("%s (Accept: %s): got %q %q", tc.path, tc.accept, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/book", nil)
+	req.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	if r.ServeHTTP(w, req); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pb", nil))
+	var msg wrapperspb.StringValue
+	if err := proto.Unmarshal(w.Body.Bytes(), &msg); err != nil || msg.GetValue() != "gee" || w.Header().Get("Content-Type") != MIMEProtoBuf {
+		t.Fatalf("unexpected protobuf response %v %q", err, msg.GetValue())
+	}
+}
+
+func TestFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(name, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2023, 8, 17, 0, 0, 0, 0, time.UTC)
+	_ = os.Chtimes(name, modTime, modTime)
+
+	r := New()
+	r.GET("/file", func(c *Context) { c.File(name) })
+	r.GET("/download", func(c *Context) { c.FileAttachment(name, "报告.txt") })
+	r.GET("/missing", func(c *Context) { c.FileAttachment(name+".bak", "") })
+
+	req := httptest.NewRequest(http.MethodGet, "/file", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	w := httptest.NewRecorder()
+	if r.ServeHTTP(w, req); w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Fatalf("range request: got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/file", nil)
+	req.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	if r.ServeHTTP(w, req); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("conditional request: got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download", nil))
+	if w.Body.String() != "0123456789" || w.Header().Get("Content-Disposition") != "attachment; filename*=utf-8''%E6%8A%A5%E5%91%8A.txt" {
+		t.Fatalf("attachment: got %q %q", w.Header().Get("Content-Disposition"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Disposition") != "" || strings.Contains(w.Body.String(), name) {
+		t.Fatalf("missing file: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestStatic(t *testing.T) {
+	assets := fstest.MapFS{
+		"index.html":      {Data: []byte("<html>app</html>")},
+		"js/app.js":       {Data: []byte("console.log('gee')")},
+		"docs/index.html": {Data: []byte("docs")},
+	}
+	r := New()
+	r.Static("/static", "./static")
+	r.StaticFile("/favicon.css", "./static/css/geektutu.css")
+	r.StaticFileWithConfig("/cached.css", "./static/css/geektutu.css", StaticConfig{MaxAge: time.Minute})
+	r.StaticFSWithConfig("/app", http.FS(assets), StaticConfig{SPA: true, MaxAge: time.Hour})
+	r.GET("/app/api/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/static/css/geektutu.css"); w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("ETag"), `W/"`) {
+		t.Fatalf("local file: got %d %v", w.Code, w.Header())
+	}
+	if w := get("/static/missing.css"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing local file should be 404, got %d", w.Code)
+	}
+	if w := get("/static/css/"); w.Code != http.StatusNotFound {
+		t.Fatalf("directory listing should be disabled, got %d", w.Code)
+	}
+	if w := get("/favicon.css"); w.Code != http.StatusOK || w.Header().Get("ETag") == "" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("static file: got %d %v", w.Code, w.Header())
+	}
+	if w := get("/cached.css"); w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("static file should use MaxAge, got %v", w.Header())
+	}
+
+	w := get("/app/js/app.js")
+	etag := w.Header().Get("ETag")
+	if w.Body.String() != "console.log('gee')" || etag == "" || w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("embedded file: got %q %v", w.Body.String(), w.Header())
+	}
+	if w = get("/app/js/app.js", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("matching ETag should be 304, got %d", w.Code)
+	}
+	if w = get("/app/docs/"); w.Body.String() != "docs" {
+		t.Fatalf("directory should serve its index, got %q", w.Body.String())
+	}
+	if w = get("/app/users/42"); w.Code != http.StatusOK || w.Body.String() != "<html>app</html>" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("unknown path should fall back to index.html, got %d %q", w.Code, w.Body.String())
+	}
+	if w = get("/app/api/ping"); w.Body.String() != "pong" {
+		t.Fatalf("registered routes should take priority, got %q", w.Body.String())
+	}
+	for _, p := range []string{"/app", "/app/"} {
+		if w = get(p); w.Code != http.StatusOK || w.Body.String() != "<html>app</html>" {
+			t.Fatalf("%s should serve index.html, got %d %q", p, w.Code, w.Body.String())
+		}
+	}
+
+	// 挂载在根路径的SPA
+	r = New()
+	r.StaticFSWithConfig("/", http.FS(assets), StaticConfig{SPA: true})
+	r.GET("/api/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+	for _, p := range []string{"/", "/users/42"} {
+		if w = get(p); w.Code != http.StatusOK || w.Body.String() != "<html>app</html>" {
+			t.Fatalf("%s should serve index.html, got %d %q", p, w.Code, w.Body.String())
+		}
+	}
+	if w = get("/api/ping"); w.Body.String() != "pong" {
+		t.Fatalf("registered routes should take priority, got %q", w.Body.String())
+	}
+}
+
+func TestHTML(t *testing.T) {
+	views := fstest.MapFS{
+		"layouts/base.tmpl":    {Data: []byte(`{{define "base"}}<title>{{block "title" .}}gee{{end}}</title>{{template "nav"}}{{block "content" .}}{{end}}{{end}}`)},
+		"partials/nav.tmpl":    {Data: []byte(`{{define "nav"}}<nav/>{{end}}`)},
+		"pages/index.tmpl":     {Data: []byte(`{{define "content"}}hello {{.}}{{end}}`)},
+		"pages/about.tmpl":     {Data: []byte(`{{define "title"}}about{{end}}{{define "content"}}{{upper .}}{{end}}`)},
+		"admin/dashboard.tmpl": {Data: []byte(`admin {{.}} {{url "about"}}`)},
+	}
+	r := New()
+	r.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	r.LoadHTML(HTMLConfig{
+		FS:      views,
+		Layouts: []string{"layouts/*.tmpl", "partials/*.tmpl"},
+		Pages:   []string{"pages/*.tmpl"},
+		Layout:  "base",
+	})
+	r.GET("/:page", func(c *Context) { c.HTML(http.StatusOK, c.Param("page")+".tmpl", "gee") })
+	r.GET("/about", func(c *Context) { c.HTML(http.StatusOK, "about.tmpl", "gee") }).SetName("about")
+	admin := r.Group("/admin")
+	admin.LoadHTML(HTMLConfig{FS: views, Pages: []string{"admin/*.tmpl"}})
+	admin.GET("/", func(c *Context) { c.HTML(http.StatusOK, "dashboard.tmpl", "gee") })
+
+	for path, expect := range map[string]string{
+		"/index":  "<title>gee</title><nav/>hello gee",
+		"/about":  "<title>about</title><nav/>GEE",
+		"/admin/": "admin gee /about",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != expect {
+			t.Fatalf("%s: expected %q, got %q", path, expect, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("undefined template should fail, got %d", w.Code)
+	}
+}
+
+func TestHTMLDebugReload(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.tmpl")
+	_ = os.WriteFile(page, []byte("v1"), 0o644)
+
+	r := New()
+	r.LoadHTML(HTMLConfig{Pages: []string{filepath.Join(dir, "*.tmpl")}, Debug: true})
+	r.GET("/", func(c *Context) { c.HTML(http.StatusOK, "page.tmpl", nil) })
+
+	for _, version := range []string{"v1", "v2"} {
+		_ = os.WriteFile(page, []byte(version), 0o644)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Body.String() != version {
+			t.Fatalf("expected %q, got %q", version, w.Body.String())
+		}
+	}
+
+	r = New()
+	r.LoadHTMLGlob("templates/*")
+	r.GET("/", func(c *Context) { c.HTML(http.StatusOK, "css.tmpl", nil) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), "geektutu.css is loaded") {
+		t.Fatalf("LoadHTMLGlob should still work, got %q", w.Body.String())
+	}
+}
+
+func listUsers(c *Context) {}
+
+func TestRoutesAndURL(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		link, _ := r.URL("file", 7, "docs/read me.md")
+		c.String(http.StatusOK, link)
+	}).SetName("home")
+	v1 := r.Group("/v1")
+	v1.GET("/users", listUsers).SetName("users")
+	v1.PUT("/users/:id/files/*path", listUsers).SetName("file")
+
+	routes := r.Routes()
+	if len(routes) != 3 || routes[2].Method != http.MethodPut || routes[2].Path != "/v1/users/:id/files/*path" ||
+		routes[1].Handler != "learn-go/src/projects/gee.listUsers" || routes[1].Name != "users" {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		params []any
+		expect string
+	}{
+		{"home", nil, "/"},
+		{"users", nil, "/v1/users"},
+		{"file", []any{7, "docs/read me.md"}, "/v1/users/7/files/docs/read%20me.md"},
+		{"file", []any{"a/b", "/x"}, "/v1/users/a%2Fb/files/x"},
+	} {
+		if url, err := r.URL(tc.name, tc.params...); err != nil || url != tc.expect {
+			t.Fatalf("URL(%s, %v) = %q, %v; expected %q", tc.name, tc.params, url, err, tc.expect)
+		}
+	}
+	for _, params := range [][]any{{7}, {7, "a", "b"}} {
+		if _, err := r.URL("file", params...); err == nil {
+			t.Fatalf("URL with params %v should fail", params)
+		}
+	}
+	if _, err := r.URL("missing"); err == nil {
+		t.Fatal("unknown route name should fail")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate route name should panic")
+		}
+	}()
+	r.GET("/other", listUsers).SetName("users")
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+			c.Next()
+		}
+	}
+
+	r := New()
+	r.Use(mark("global"))
+	v1 := r.Group("/v1")
+	v1.Use(mark("v1"))
+	v10 := r.Group("/v10")
+	v10.GET("/ping", func(c *Context) { trace = append(trace, "ping") })
+	v1.GET("/users/:id", mark("route"), func(c *Context) { trace = append(trace, "user") })
+	admin := v1.Group("/admin")
+	admin.Use(mark("admin"))
+	admin.GET("/", func(c *Context) { trace = append(trace, "dashboard") })
+
+	for path, expect := range map[string][]string{
+		"/v10/ping":     {"global", "ping"},
+		"/v1/users/1":   {"global", "v1", "route", "user"},
+		"/v1/admin":     {"global", "v1", "admin", "dashboard"},
+		"/v1/missing":   {"global", "v1"},
+		"/v10/missing":  {"global"},
+		"/v1admin/ping": {"global"},
+	} {
+		trace = nil
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if !reflect.DeepEqual(trace, expect) {
+			t.Fatalf("%s: expected %v, got %v", path, expect, trace)
+		}
+	}
+}
+
+func benchmarkRoute(b *testing.B, path string) {
+	r := New()
+	r.Use(func(c *Context) { c.Next() })
+	r.GET("/", func(c *Context) {})
+	r.GET("/hello/b/c", func(c *Context) {})
+	r.GET("/hello/:name", func(c *Context) { _ = c.Param("name") })
+	r.GET("/users/:id/books/:book", func(c *Context) { _ = c.Param("book") })
+	r.GET("/assets/*filepath", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkStaticRoute(b *testing.B) {
+	benchmarkRoute(b, "/hello/b/c")
+}
+
+func BenchmarkParamRoute(b *testing.B) {
+	benchmarkRoute(b, "/users/1/books/2")
 }
 
 type student struct {
@@ -0,0 +1,230 @@
+// Package geetest 在进程内测试gee处理器，无需监听端口
+package geetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"learn-go/src/projects/gee"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Client 对同一个Engine发起测试请求
+type Client struct {
+	t      testing.TB
+	engine *gee.Engine
+}
+
+// New 创建测试客户端，engine为nil时使用gee.New()，此时只能通过 RequestBuilder.Call 测试单个处理器
+func New(t testing.TB, engine *gee.Engine) *Client {
+	if engine == nil {
+		engine = gee.New()
+	}
+	return &Client{t: t, engine: engine}
+}
+
+// RequestBuilder 链式构造测试请求
+type RequestBuilder struct {
+	client *Client
+	method string
+	target string
+	header http.Header
+	query  url.Values
+	body   []byte
+	params gee.Params     // 仅用于Call
+	keys   map[string]any // 仅用于Call
+}
+
+// Request 构造任意方法的请求，target可以带查询参数 如：/users?page=1
+func (c *Client) Request(method, target string) *RequestBuilder {
+	return &RequestBuilder{
+		client: c,
+		method: method,
+		target: target,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+func (c *Client) GET(target string) *RequestBuilder {
+	return c.Request(http.MethodGet, target)
+}
+
+func (c *Client) POST(target string) *RequestBuilder {
+	return c.Request(http.MethodPost, target)
+}
+
+func (c *Client) PUT(target string) *RequestBuilder {
+	return c.Request(http.MethodPut, target)
+}
+
+func (c *Client) PATCH(target string) *RequestBuilder {
+	return c.Request(http.MethodPatch, target)
+}
+
+func (c *Client) DELETE(target string) *RequestBuilder {
+	return c.Request(http.MethodDelete, target)
+}
+
+// Header 设置请求头
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Set(key, value)
+	return b
+}
+
+// Query 追加查询参数
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Body 设置原始请求体
+func (b *RequestBuilder) Body(contentType string, body []byte) *RequestBuilder {
+	b.header.Set("Content-Type", contentType)
+	b.body = body
+	return b
+}
+
+// JSON 将obj序列化为JSON请求体
+func (b *RequestBuilder) JSON(obj any) *RequestBuilder {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		b.client.t.Helper()
+		b.client.t.Fatalf("geetest: marshal json body: %v", err)
+	}
+	return b.Body(gee.MIMEJSON, data)
+}
+
+// Form 设置表单请求体
+func (b *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	return b.Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// Param 设置路由参数，仅在Call时生效，经过路由的请求由路由解析参数
+func (b *RequestBuilder) Param(key, value string) *RequestBuilder {
+	b.params = append(b.params, gee.Param{Key: key, Value: value})
+	return b
+}
+
+// Set 预先写入 Context.Keys，模拟上游中间件的结果，仅在Call时生效
+func (b *RequestBuilder) Set(key string, value any) *RequestBuilder {
+	if b.keys == nil {
+		b.keys = make(map[string]any)
+	}
+	b.keys[key] = value
+	return b
+}
+
+// 构造*http.Request
+func (b *RequestBuilder) build() *http.Request {
+	var body io.Reader
+	if b.body != nil {
+		body = bytes.NewReader(b.body)
+	}
+	req := httptest.NewRequest(b.method, b.target, body)
+	for k, v := range b.header {
+		req.Header[k] = v
+	}
+	if len(b.query) > 0 {
+		q := req.URL.Query()
+		for k, v := range b.query {
+			q[k] = append(q[k], v...)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	return req
+}
+
+// Do 经过完整的路由和中间件处理请求
+func (b *RequestBuilder) Do() *Response {
+	w := httptest.NewRecorder()
+	b.client.engine.ServeHTTP(w, b.build())
+	return &Response{ResponseRecorder: w, t: b.client.t}
+}
+
+// Call 不经过路由和中间件，使用准备好的Context直接调用单个处理器
+func (b *RequestBuilder) Call(handler gee.HandlerFunc) *Response {
+	w := httptest.NewRecorder()
+	c := b.client.engine.NewContext(w, b.build())
+	c.Params = append(c.Params, b.params...)
+	for k, v := range b.keys {
+		c.Set(k, v)
+	}
+	handler(c)
+	c.Writer.WriteHeaderNow()
+	return &Response{ResponseRecorder: w, t: b.client.t}
+}
+
+// Response 测试响应，断言失败时通过t.Errorf报告并继续执行
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+// ExpectStatus 断言状态码
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Errorf("geetest: expected status %d, got %d (body: %q)", code, r.Code, r.Body.String())
+	}
+	return r
+}
+
+// ExpectHeader 断言响应头
+func (r *Response) ExpectHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header().Get(key); got != value {
+		r.t.Errorf("geetest: expected header %s: %q, got %q", key, value, got)
+	}
+	return r
+}
+
+// ExpectBody 断言响应体
+func (r *Response) ExpectBody(body string) *Response {
+	r.t.Helper()
+	if got := r.Body.String(); got != body {
+		r.t.Errorf("geetest: expected body %q, got %q", body, got)
+	}
+	return r
+}
+
+// ExpectBodyContains 断言响应体包含substr
+func (r *Response) ExpectBodyContains(substr string) *Response {
+	r.t.Helper()
+	if got := r.Body.String(); !strings.Contains(got, substr) {
+		r.t.Errorf("geetest: expected body to contain %q, got %q", substr, got)
+	}
+	return r
+}
+
+// ExpectJSON 将响应体解码为与expected相同的类型后比较
+func (r *Response) ExpectJSON(expected any) *Response {
+	r.t.Helper()
+	typ := reflect.TypeOf(expected)
+	if typ == nil {
+		r.t.Fatal("geetest: expected json must not be nil")
+	}
+	got := reflect.New(typ)
+	if err := json.Unmarshal(r.Body.Bytes(), got.Interface()); err != nil {
+		r.t.Errorf("geetest: decode json body %q: %v", r.Body.String(), err)
+		return r
+	}
+	if !reflect.DeepEqual(got.Elem().Interface(), expected) {
+		r.t.Errorf("geetest: expected json %+v, got %+v", expected, got.Elem().Interface())
+	}
+	return r
+}
+
+// DecodeJSON 将响应体解码到v，失败时终止测试
+func (r *Response) DecodeJSON(v any) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("geetest: decode json body %q: %v", r.Body.String(), err)
+	}
+	return r
+}
@@ -0,0 +1,94 @@
+package geetest
+
+import (
+	"fmt"
+	"learn-go/src/projects/gee"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type user struct {
+	Name string `json:"name" form:"name" binding:"required"`
+	Age  int    `json:"age" form:"age"`
+}
+
+func newEngine() *gee.Engine {
+	r := gee.New()
+	r.POST("/users", func(c *gee.Context) {
+		var u user
+		if err := c.Bind(&u); err != nil {
+			c.Fail(http.StatusBadRequest, err)
+			return
+		}
+		c.SetHeader("Location", "/users/"+u.Name)
+		c.JSON(http.StatusCreated, u)
+	})
+	r.GET("/search", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s %s", c.Query("q"), c.Req.Header.Get("X-Token"))
+	})
+	return r
+}
+
+func TestDo(t *testing.T) {
+	client := New(t, newEngine())
+
+	client.POST("/users").JSON(user{Name: "geektutu", Age: 20}).Do().
+		ExpectStatus(http.StatusCreated).
+		ExpectHeader("Location", "/users/geektutu").
+		ExpectJSON(user{Name: "geektutu", Age: 20})
+
+	var u user
+	client.POST("/users").Form(url.Values{"name": {"jack"}, "age": {"22"}}).Do().
+		ExpectStatus(http.StatusCreated).
+		DecodeJSON(&u)
+	if u.Name != "jack" || u.Age != 22 {
+		t.Fatalf("unexpected user %+v", u)
+	}
+
+	client.POST("/users").JSON(user{Age: 1}).Do().
+		ExpectStatus(http.StatusBadRequest).
+		ExpectBodyContains("required")
+
+	client.GET("/search?q=gee").Header("X-Token", "secret").Do().
+		ExpectBody("gee secret")
+	client.GET("/search").Query("q", "a b").Do().
+		ExpectBody("a b ")
+}
+
+func TestCall(t *testing.T) {
+	profile := func(c *gee.Context) {
+		c.String(http.StatusOK, "%s:%s", c.Param("id"), c.GetString("user"))
+	}
+
+	New(t, nil).GET("/users/1").Param("id", "1").Set("user", "geektutu").Call(profile).
+		ExpectStatus(http.StatusOK).
+		ExpectBody("1:geektutu")
+
+	New(t, nil).DELETE("/users/1").Call(func(c *gee.Context) { c.Status(http.StatusNoContent) }).
+		ExpectStatus(http.StatusNoContent)
+}
+
+// 记录断言失败而不终止测试
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestExpectFailures(t *testing.T) {
+	rt := &recordingT{TB: t}
+	New(rt, newEngine()).GET("/search?q=gee").Do().
+		ExpectStatus(http.StatusTeapot).
+		ExpectHeader("X-Missing", "1").
+		ExpectBody("other").
+		ExpectJSON(map[string]any{})
+	if len(rt.errors) != 4 {
+		t.Fatalf("expected 4 failed assertions, got %v", rt.errors)
+	}
+}
@@ -0,0 +1,145 @@
+package gee
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// HTMLConfig 模板集合的加载配置
+// 页面通过{{define}}覆盖布局中的{{block}}实现继承，每个页面单独解析，不同页面可以定义同名的块
+type HTMLConfig struct {
+	FS      fs.FS    // 模板所在的文件系统，如embed.FS，为nil时读取本地文件
+	Layouts []string // 布局和公共片段的glob模式，所有页面共享
+	Pages   []string // 页面的glob模式，按文件名渲染
+	Layout  string   // 渲染页面时执行的布局模板名，为空时执行页面本身
+	Debug   bool     // 每次渲染前重新解析模板，修改模板后无需重启
+}
+
+// 一组已解析的模板
+type htmlRender struct {
+	config  HTMLConfig
+	engine  *Engine
+	layouts *template.Template            // 布局和片段，没有页面时即为全部模板
+	pages   map[string]*template.Template // 页面名 -> 继承了布局的页面模板
+}
+
+// LoadHTML 为分组加载模板集合，分组及其子分组下的 c.HTML 优先使用最近的模板集合，解析失败时panic
+func (g *RouterGroup) LoadHTML(config HTMLConfig) {
+	h := &htmlRender{config: config, engine: g.engine}
+	if err := h.load(); err != nil {
+		panic(err)
+	}
+	g.html = h
+}
+
+// LoadHTMLFS 从fs.FS中加载模板，如 r.LoadHTMLFS(templatesFS, "templates/*.tmpl")
+func (e *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	e.LoadHTML(HTMLConfig{FS: fsys, Layouts: patterns})
+}
+
+// 解析全部模板
+func (h *htmlRender) load() error {
+	layouts := template.New("").Funcs(template.FuncMap{"url": h.engine.URL}).Funcs(h.engine.funcMap)
+	files, err := h.glob(h.config.Layouts)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = h.parse(layouts, file); err != nil {
+			return err
+		}
+	}
+
+	if files, err = h.glob(h.config.Pages); err != nil {
+		return err
+	}
+	pages := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		page, err := layouts.Clone()
+		if err != nil {
+			return err
+		}
+		if err = h.parse(page, file); err != nil {
+			return err
+		}
+		pages[filepath.Base(file)] = page
+	}
+
+	h.layouts, h.pages = layouts, pages
+	return nil
+}
+
+// 展开glob模式，任一模式没有匹配的文件时返回错误
+func (h *htmlRender) glob(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		var matches []string
+		var err error
+		if h.config.FS != nil {
+			matches, err = fs.Glob(h.config.FS, pattern)
+		} else {
+			matches, err = filepath.Glob(pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("gee: template pattern %q matches no files", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// 以文件名为模板名解析文件
+func (h *htmlRender) parse(t *template.Template, file string) error {
+	var data []byte
+	var err error
+	if h.config.FS != nil {
+		data, err = fs.ReadFile(h.config.FS, file)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = t.New(filepath.Base(file)).Parse(string(data))
+	return err
+}
+
+// 返回用于渲染name的模板集合及要执行的模板名
+func (h *htmlRender) lookup(name string) (*template.Template, string, error) {
+	if h.config.Debug {
+		fresh := &htmlRender{config: h.config, engine: h.engine}
+		if err := fresh.load(); err != nil {
+			return nil, "", err
+		}
+		h = fresh
+	}
+
+	if page, ok := h.pages[name]; ok {
+		if h.config.Layout != "" {
+			return page, h.config.Layout, nil
+		}
+		return page, name, nil
+	}
+	if h.layouts.Lookup(name) == nil {
+		return nil, "", fmt.Errorf("gee: html template %q is undefined", name)
+	}
+	return h.layouts, name, nil
+}
+
+// 查找请求路径所属的最近一个加载了模板的分组
+func (e *Engine) htmlRender(path string) *htmlRender {
+	var h *htmlRender
+	prefix := -1
+	for _, group := range e.groups {
+		if group.html != nil && len(group.prefix) > prefix && hasPathPrefix(path, group.prefix) {
+			h, prefix = group.html, len(group.prefix)
+		}
+	}
+	return h
+}
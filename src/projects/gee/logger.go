@@ -10,6 +10,6 @@ func Logger() HandlerFunc {
 	return func(c *Context) {
 		t := time.Now()
 		c.Next()
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		log.Printf("[%d] %s %dB in %v", c.Writer.Status(), c.Req.RequestURI, c.Writer.Size(), time.Since(t))
 	}
 }
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"fmt"
+	"learn-go/src/projects/gee"
+	"net/http"
+)
+
+// BodyLimit 限制请求体的最大字节数
+// Content-Length超出限制时直接响应413，否则读取超出限制的部分时返回*http.MaxBytesError
+func BodyLimit(limit int64) gee.HandlerFunc {
+	return func(c *gee.Context) {
+		if c.Req.ContentLength > limit {
+			c.Fail(http.StatusRequestEntityTooLarge, fmt.Errorf("request body too large: limit %d bytes", limit))
+			return
+		}
+		if c.Req.Body != nil {
+			c.Req.Body = http.MaxBytesReader(c.Writer, c.Req.Body, limit)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"learn-go/src/projects/gee"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig 跨域资源共享配置
+type CORSConfig struct {
+	AllowOrigins     []string      // 允许的来源，为空或包含"*"时允许任意来源（此时不能开启AllowCredentials）
+	AllowMethods     []string      // 预检响应中允许的方法，为空时使用常用方法
+	AllowHeaders     []string      // 预检响应中允许的请求头，为空时允许预检请求所声明的全部请求头
+	ExposeHeaders    []string      // 允许浏览器读取的响应头
+	AllowCredentials bool          // 是否允许携带cookie等凭证，开启时必须明确列出AllowOrigins
+	MaxAge           time.Duration // 预检结果的缓存时间
+}
+
+var defaultAllowMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead,
+}
+
+// CORS 跨域中间件
+// 通过 Engine.Pre 注册时，预检请求在路由匹配之前就会被响应，无需为每个路由注册OPTIONS处理器
+// 允许任意来源的同时允许携带凭证会让任何网站都能以用户身份发起请求，这种配置会直接panic
+func CORS(config CORSConfig) gee.HandlerFunc {
+	allowAll := len(config.AllowOrigins) == 0
+	origins := make(map[string]bool, len(config.AllowOrigins))
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		origins[strings.ToLower(origin)] = true
+	}
+	if allowAll && config.AllowCredentials {
+		panic("gee: CORS with AllowCredentials requires an explicit AllowOrigins list")
+	}
+
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultAllowMethods
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge / time.Second))
+
+	return func(c *gee.Context) {
+		origin := c.Req.Header.Get("Origin")
+		if origin == "" {
+			// 非跨域请求
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != ""
+
+		if !allowAll && !origins[strings.ToLower(origin)] {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if requested := c.Req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
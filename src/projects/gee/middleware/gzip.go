@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"learn-go/src/projects/gee"
+	"net/http"
+	"strings"
+)
+
+// GzipConfig 响应压缩配置
+type GzipConfig struct {
+	Level        int      // 压缩级别，默认flate.DefaultCompression
+	MinLength    int      // 响应体达到该大小才压缩，默认1024字节
+	ContentTypes []string // 可压缩的Content-Type前缀，默认为文本、JSON、JavaScript、XML和SVG
+}
+
+var defaultCompressTypes = []string{
+	"text/", "application/json", "application/javascript", "application/xml", "image/svg+xml",
+}
+
+// Gzip 使用默认配置的压缩中间件
+func Gzip() gee.HandlerFunc {
+	return GzipWithConfig(GzipConfig{})
+}
+
+// GzipWithConfig 按Accept-Encoding选择gzip或deflate压缩响应体
+func GzipWithConfig(config GzipConfig) gee.HandlerFunc {
+	if config.Level == 0 {
+		config.Level = flate.DefaultCompression
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = 1024
+	}
+	if len(config.ContentTypes) == 0 {
+		config.ContentTypes = defaultCompressTypes
+	}
+	// 提前校验压缩级别
+	if _, err := gzip.NewWriterLevel(io.Discard, config.Level); err != nil {
+		panic(err)
+	}
+
+	return func(c *gee.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptEncoding(c.Req.Header.Get("Accept-Encoding"))
+		if encoding == "" || c.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: c.Writer, config: &config, encoding: encoding}
+		c.Writer = cw
+		defer func() {
+			cw.finish()
+			c.Writer = cw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// 选择客户端支持的编码，优先gzip
+func acceptEncoding(header string) string {
+	var gz, deflate bool
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q := strings.TrimSpace(params); q == "q=0" || q == "q=0.0" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "*":
+			gz = true
+		case "deflate":
+			deflate = true
+		}
+	}
+	switch {
+	case gz:
+		return "gzip"
+	case deflate:
+		return "deflate"
+	}
+	return ""
+}
+
+// 压缩响应的ResponseWriter，先缓冲MinLength字节再决定是否压缩
+type compressWriter struct {
+	gee.ResponseWriter
+	config   *GzipConfig
+	encoding string
+	buf      []byte         // 决定是否压缩前缓冲的数据
+	decided  bool           // 是否已决定压缩方式
+	cw       io.WriteCloser // 压缩器，不压缩时为nil
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) >= w.config.MinLength {
+			if err := w.decide(); err != nil {
+				return 0, err
+			}
+		}
+		return len(data), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written 缓冲中的数据也视为已写出，避免后续中间件再写入状态码
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	// 响应头已发送时无法再设置Content-Encoding
+	if w.ResponseWriter.Written() || header.Get("Content-Encoding") != "" || len(w.buf) < w.config.MinLength {
+		return false
+	}
+	switch w.Status() {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(w.buf)
+		header.Set("Content-Type", ct)
+	}
+	for _, prefix := range w.config.ContentTypes {
+		if strings.HasPrefix(ct, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 根据缓冲的数据决定是否压缩，并将其写出
+func (w *compressWriter) decide() error {
+	w.decided = true
+	compress := w.compressible()
+	buf := w.buf
+	w.buf = nil
+
+	if compress {
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if w.encoding == "gzip" {
+			w.cw, _ = gzip.NewWriterLevel(w.ResponseWriter, w.config.Level)
+		} else {
+			w.cw, _ = flate.NewWriter(w.ResponseWriter, w.config.Level)
+		}
+		_, err := w.cw.Write(buf)
+		return err
+	}
+
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// 处理器返回后写出剩余数据并结束压缩流
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		_ = w.decide()
+	}
+	if w.cw != nil {
+		_ = w.cw.Close()
+	}
+}
@@ -0,0 +1,355 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"learn-go/src/projects/gee"
+	"learn-go/src/projects/geecache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(e *gee.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	r := gee.New()
+	r.Pre(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Request-ID"},
+		MaxAge:           time.Hour,
+	}))
+	r.POST("/api/users", func(c *gee.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	// 预检请求在路由之前被响应，即使没有注册OPTIONS路由
+	req := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := serve(r, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Content-Type" || w.Header().Get("Access-Control-Max-Age") != "3600" {
+		t.Fatalf("unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	w = serve(r, req)
+	if w.Body.String() != "ok" || w.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Fatalf("unexpected cors response: %v", w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	if w = serve(r, req); w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin should be rejected, got %d", w.Code)
+	}
+
+	// 任意来源与凭证不能同时开启
+	for _, origins := range [][]string{nil, {"https://example.com", "*"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("wildcard origins %v with credentials should panic", origins)
+				}
+			}()
+			CORS(CORSConfig{AllowOrigins: origins, AllowCredentials: true})
+		}()
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	r := gee.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gee.Context) {
+		if c.GetString(RequestIDKey) != RequestIDFromContext(c.Req.Context()) {
+			c.String(http.StatusInternalServerError, "request id mismatch")
+			return
+		}
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/", nil))
+	if id := w.Header().Get(HeaderXRequestID); len(id) != 32 || w.Body.String() != id {
+		t.Fatalf("request id should be generated, got %q", id)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderXRequestID, "upstream-id")
+	if w = serve(r, req); w.Body.String() != "upstream-id" || w.Header().Get(HeaderXRequestID) != "upstream-id" {
+		t.Fatalf("request id should be propagated, got %q", w.Body.String())
+	}
+}
+
+func TestGzip(t *testing.T) {
+	r := gee.New()
+	r.Use(GzipWithConfig(GzipConfig{MinLength: 10}))
+	r.GET("/big", func(c *gee.Context) {
+		c.String(http.StatusOK, strings.Repeat("gee", 100))
+	})
+	r.GET("/small", func(c *gee.Context) {
+		c.String(http.StatusOK, "gee")
+	})
+	r.GET("/png", func(c *gee.Context) {
+		c.SetHeader("Content-Type", "image/png")
+		c.Data(http.StatusOK, make([]byte, 100))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/big", nil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip")
+	w := serve(r, req)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("response should be compressed, got %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != strings.Repeat("gee", 100) {
+		t.Fatalf("unexpected decompressed body %q", body)
+	}
+
+	for _, path := range []string{"/small", "/png"} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if w = serve(r, req); w.Header().Get("Content-Encoding") != "" || w.Body.Len() == 0 {
+			t.Fatalf("%s should not be compressed", path)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := gee.New()
+	r.Use(Timeout(20 * time.Millisecond))
+	r.GET("/slow", func(c *gee.Context) {
+		c.SetHeader("X-Partial", "1")
+		c.String(http.StatusOK, "partial")
+		<-c.Req.Context().Done()
+	})
+	r.GET("/fast", func(c *gee.Context) {
+		c.SetHeader("X-Fast", "1")
+		c.String(http.StatusCreated, "fast")
+	})
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Partial") != "" || strings.Contains(w.Body.String(), "partial") {
+		t.Fatalf("expected timeout response, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = serve(r, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if w.Code != http.StatusCreated || w.Header().Get("X-Fast") != "1" || w.Body.String() != "fast" {
+		t.Fatalf("expected buffered response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutIgnoreCtx(t *testing.T) {
+	r := gee.New()
+	r.Use(Timeout(20 * time.Millisecond))
+	writeErr := make(chan error, 1)
+	r.GET("/sleep", func(c *gee.Context) {
+		time.Sleep(300 * time.Millisecond)
+		_, err := c.Writer.Write([]byte("late"))
+		writeErr <- err
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	start := time.Now()
+	resp, err := http.Get(ts.URL + "/sleep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("timeout response should not wait for the handler, took %v", elapsed)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || strings.Contains(string(body), "late") {
+		t.Fatalf("expected timeout response, got %d %q", resp.StatusCode, body)
+	}
+	if err = <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Fatalf("write after timeout should fail, got %v", err)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	r := gee.New()
+	r.Use(BodyLimit(4))
+	var handled bool
+	r.POST("/", func(c *gee.Context) {
+		handled = true
+		_, err := io.ReadAll(c.Req.Body)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.Fail(http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	if w := serve(r, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too large"))); w.Code != http.StatusRequestEntityTooLarge || handled {
+		t.Fatalf("oversized Content-Length should be rejected before the handler, got %d", w.Code)
+	}
+
+	// 未知长度的请求体在读取时被截断
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("too large")))
+	req.ContentLength = -1
+	if w := serve(r, req); w.Code != http.StatusRequestEntityTooLarge || !handled {
+		t.Fatalf("oversized body should fail while reading, got %d", w.Code)
+	}
+
+	if w := serve(r, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ok"))); w.Code != http.StatusOK {
+		t.Fatalf("small body should pass, got %d", w.Code)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := TokenBucket(2, time.Second)
+	now := time.Unix(1700000000, 0)
+	var state []byte
+	take := func(at time.Time) RateLimitResult {
+		var result RateLimitResult
+		result, state = bucket.Allow(state, at)
+		return result
+	}
+
+	// 允许突发两个请求，之后按每500ms一个令牌恢复
+	if r := take(now); !r.Allowed || r.Remaining != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := take(now); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := take(now); r.Allowed || r.RetryAfter != 500*time.Millisecond || r.Reset != time.Second {
+		t.Fatalf("bucket should be empty, got %+v", r)
+	}
+	if r := take(now.Add(500 * time.Millisecond)); !r.Allowed {
+		t.Fatalf("token should be refilled, got %+v", r)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	window := SlidingWindow(4, time.Minute)
+	start := time.Unix(1700000000/60*60, 0)
+	var state []byte
+	take := func(at time.Time) RateLimitResult {
+		var result RateLimitResult
+		result, state = window.Allow(state, at)
+		return result
+	}
+
+	for i := 0; i < 4; i++ {
+		if r := take(start.Add(50 * time.Second)); !r.Allowed || r.Remaining != 3-i {
+			t.Fatalf("request %d: unexpected result %+v", i, r)
+		}
+	}
+	if r := take(start.Add(50 * time.Second)); r.Allowed || r.RetryAfter != 10*time.Second {
+		t.Fatalf("window should be full, got %+v", r)
+	}
+
+	// 下一个窗口开始15秒时，上一个窗口仍计入 4*45/60=3 个请求
+	next := start.Add(75 * time.Second)
+	if r := take(next); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := take(next); r.Allowed || r.RetryAfter != 15*time.Second {
+		t.Fatalf("previous window should still count, got %+v", r)
+	}
+	if r := take(start.Add(3 * time.Minute)); !r.Allowed || r.Remaining != 3 {
+		t.Fatalf("old windows should be forgotten, got %+v", r)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Update(string, time.Duration, func([]byte) []byte) error {
+	return errors.New("store unavailable")
+}
+
+func TestRateLimit(t *testing.T) {
+	store := NewMemoryStore(4)
+	r := gee.New()
+	r.Use(RateLimit(RateLimitConfig{Algorithm: TokenBucket(2, time.Minute), Store: store}))
+	r.GET("/", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	api := r.Group("/api")
+	api.Use(RateLimit(RateLimitConfig{Algorithm: SlidingWindow(1, time.Minute), KeyFunc: KeyByHeader("X-API-Key")}))
+	api.GET("/", func(c *gee.Context) { c.String(http.StatusOK, "api") })
+	var errs gee.Errors
+	r.GET("/open", func(c *gee.Context) {
+		c.Next()
+		errs = c.Errors
+	}, RateLimit(RateLimitConfig{Store: failingStore{}}), func(c *gee.Context) {
+		c.String(http.StatusOK, "open")
+	})
+
+	get := func(path, remoteAddr, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		return serve(r, req)
+	}
+
+	get("/", "10.0.0.1:1234", "")
+	w := get("/", "10.0.0.1:5678", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	w = get("/", "10.0.0.1:1234", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("third request should be limited, got %d %v", w.Code, w.Header())
+	}
+	if w = get("/", "10.0.0.2:1234", ""); w.Code != http.StatusOK {
+		t.Fatalf("other clients should not be limited, got %d", w.Code)
+	}
+	if store.Len() == 0 {
+		t.Fatal("state should be kept in the memory store")
+	}
+
+	// 全局按IP的额度已用完，api分组按API Key单独限流
+	if w = get("/api/", "10.0.0.3:1234", "key-a"); w.Body.String() != "api" {
+		t.Fatalf("unexpected response %d", w.Code)
+	}
+	if w = get("/api/", "10.0.0.4:1234", "key-a"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("same api key should be limited, got %d", w.Code)
+	}
+
+	if w = get("/open", "10.0.0.5:1234", ""); w.Body.String() != "open" || len(errs) != 1 {
+		t.Fatalf("store errors should fail open, got %q %v", w.Body.String(), errs)
+	}
+}
+
+func TestRateLimitGeeCache(t *testing.T) {
+	group := geecache.NewGroup("ratelimit", 64<<10, geecache.GetterFunc(func(key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}))
+	r := gee.New()
+	r.Use(RateLimit(RateLimitConfig{Algorithm: TokenBucket(2, time.Minute), Store: group}))
+	r.GET("/", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		codes[i] = serve(r, req).Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("geecache group should keep the limiter state, got %v", codes)
+	}
+	if stats := group.CacheStats(geecache.MainCache); stats.Items != 1 {
+		t.Fatalf("state should be stored in the group, got %+v", stats)
+	}
+}
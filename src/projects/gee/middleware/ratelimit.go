@@ -0,0 +1,292 @@
+package middleware
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"learn-go/src/projects/gee"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 窗口内允许的请求数
+	Remaining  int           // 窗口内剩余的请求数
+	Reset      time.Duration // 多久后额度完全恢复
+	RetryAfter time.Duration // 被拒绝时多久后可以重试
+}
+
+// RateLimitAlgorithm 限流算法，状态编码为字节切片以便保存在任意存储中
+type RateLimitAlgorithm interface {
+	// Allow 根据旧状态（不存在时为nil）判断本次请求是否放行，并返回新状态
+	Allow(state []byte, now time.Time) (RateLimitResult, []byte)
+	// TTL 状态闲置超过该时长后与不存在等价，存储可以将其丢弃
+	TTL() time.Duration
+}
+
+// RateLimitStore 限流状态的存储，同一个key的Update应当是原子的读-改-写，否则并发请求会相互覆盖状态而多放行一些请求
+// 默认使用进程内的 MemoryStore；*geecache.Group 也实现了该接口，状态随缓存组淘汰和过期，
+// 但只在单个节点内原子，多节点时各节点分别计数（尽力而为），需要全局准确的限流时应使用Redis等支持原子更新的存储
+type RateLimitStore interface {
+	// Update 以key当前的状态（不存在或已过期时为nil）调用fn，并保存fn返回的新状态ttl时长
+	Update(key string, ttl time.Duration, fn func(state []byte) []byte) error
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm          // 限流算法，默认每分钟60次的令牌桶
+	KeyFunc   func(c *gee.Context) string // 限流的维度，默认按客户端IP，返回空串时不限流
+	Store     RateLimitStore              // 状态存储，默认 NewMemoryStore(32)
+	Handler   gee.HandlerFunc             // 被限流时的处理器，默认响应429
+}
+
+// RateLimit 限流中间件，响应头中携带X-RateLimit-Limit、X-RateLimit-Remaining和X-RateLimit-Reset，被拒绝时携带Retry-After
+// 存储出错时放行请求，错误记录在 Context.Errors 中
+func RateLimit(config RateLimitConfig) gee.HandlerFunc {
+	if config.Algorithm == nil {
+		config.Algorithm = TokenBucket(60, time.Minute)
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = KeyByIP()
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore(32)
+	}
+	if config.Handler == nil {
+		config.Handler = func(c *gee.Context) {
+			c.Fail(http.StatusTooManyRequests, errors.New("429 TOO MANY REQUESTS"))
+		}
+	}
+
+	return func(c *gee.Context) {
+		key := config.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		var result RateLimitResult
+		err := config.Store.Update(key, config.Algorithm.TTL(), func(state []byte) []byte {
+			var next []byte
+			result, next = config.Algorithm.Allow(state, time.Now())
+			return next
+		})
+		if err != nil {
+			_ = c.Error(err)
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		if !result.Allowed {
+			header.Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+			config.Handler(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// KeyByIP 按客户端IP限流，使用连接的远端地址，不信任可伪造的X-Forwarded-For
+func KeyByIP() func(c *gee.Context) string {
+	return func(c *gee.Context) string {
+		host, _, err := net.SplitHostPort(c.Req.RemoteAddr)
+		if err != nil {
+			return c.Req.RemoteAddr
+		}
+		return host
+	}
+}
+
+// KeyByHeader 按请求头限流 如：API Key，请求未携带该请求头时不限流
+func KeyByHeader(name string) func(c *gee.Context) string {
+	return func(c *gee.Context) string {
+		return c.Req.Header.Get(name)
+	}
+}
+
+// 向上取整的秒数
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// 令牌桶：容量为limit，每window补满limit个令牌，允许突发
+type tokenBucket struct {
+	limit  int
+	window time.Duration
+	rate   float64 // 每纳秒补充的令牌数
+}
+
+// TokenBucket 令牌桶算法，允许最多limit个请求的突发，平均速率为每window limit个
+func TokenBucket(limit int, window time.Duration) RateLimitAlgorithm {
+	if limit <= 0 || window <= 0 {
+		panic("gee: rate limit and window must be positive")
+	}
+	return &tokenBucket{limit: limit, window: window, rate: float64(limit) / float64(window)}
+}
+
+func (b *tokenBucket) TTL() time.Duration {
+	return b.window
+}
+
+// 状态：剩余令牌数(float64) + 上次更新时间(int64纳秒)
+func (b *tokenBucket) Allow(state []byte, now time.Time) (RateLimitResult, []byte) {
+	tokens, last := float64(b.limit), now.UnixNano()
+	if len(state) == 16 {
+		tokens = math.Float64frombits(binary.BigEndian.Uint64(state))
+		last = int64(binary.BigEndian.Uint64(state[8:]))
+	}
+	if elapsed := now.UnixNano() - last; elapsed > 0 {
+		tokens = math.Min(float64(b.limit), tokens+float64(elapsed)*b.rate)
+	}
+
+	result := RateLimitResult{Limit: b.limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) / b.rate))
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration(math.Ceil((float64(b.limit) - tokens) / b.rate))
+
+	next := make([]byte, 16)
+	binary.BigEndian.PutUint64(next, math.Float64bits(tokens))
+	binary.BigEndian.PutUint64(next[8:], uint64(now.UnixNano()))
+	return result, next
+}
+
+// 滑动窗口：按上一个窗口的计数加权估算最近window内的请求数，避免固定窗口边界处的双倍突发
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// SlidingWindow 滑动窗口算法，任意连续window内至多约limit个请求
+func SlidingWindow(limit int, window time.Duration) RateLimitAlgorithm {
+	if limit <= 0 || window <= 0 {
+		panic("gee: rate limit and window must be positive")
+	}
+	return &slidingWindow{limit: limit, window: window}
+}
+
+func (w *slidingWindow) TTL() time.Duration {
+	return 2 * w.window
+}
+
+// 状态：当前窗口的起始时间(int64纳秒) + 上一个窗口的计数 + 当前窗口的计数
+func (w *slidingWindow) Allow(state []byte, now time.Time) (RateLimitResult, []byte) {
+	size := int64(w.window)
+	start := now.UnixNano() / size * size
+	var prev, cur int64
+	if len(state) == 24 {
+		switch last := int64(binary.BigEndian.Uint64(state)); {
+		case last == start:
+			prev = int64(binary.BigEndian.Uint64(state[8:]))
+			cur = int64(binary.BigEndian.Uint64(state[16:]))
+		case last == start-size:
+			prev = int64(binary.BigEndian.Uint64(state[16:]))
+		}
+	}
+
+	elapsed := now.UnixNano() - start
+	weight := float64(size-elapsed) / float64(size)
+	estimate := float64(prev)*weight + float64(cur)
+	result := RateLimitResult{Limit: w.limit, Reset: time.Duration(size - elapsed)}
+	if estimate+1 <= float64(w.limit) {
+		cur++
+		estimate++
+		result.Allowed = true
+	} else if cur+1 > int64(w.limit) || prev == 0 {
+		// 当前窗口已满，至少要等到下一个窗口
+		result.RetryAfter = time.Duration(size - elapsed)
+	} else {
+		// 等待上一个窗口的权重下降到足以放行一个请求
+		need := 1 - (float64(int64(w.limit)-cur-1))/float64(prev)
+		result.RetryAfter = time.Duration(math.Ceil(need*float64(size))) - time.Duration(elapsed)
+	}
+	result.Remaining = int(math.Max(0, float64(w.limit)-math.Ceil(estimate)))
+
+	next := make([]byte, 24)
+	binary.BigEndian.PutUint64(next, uint64(start))
+	binary.BigEndian.PutUint64(next[8:], uint64(prev))
+	binary.BigEndian.PutUint64(next[16:], uint64(cur))
+	return result, next
+}
+
+// MemoryStore 进程内的分片存储，每个分片有独立的锁，过期状态在写入时顺带清理
+type MemoryStore struct {
+	shards []*memoryShard
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	swept   time.Time // 上次清理的时间
+}
+
+type memoryEntry struct {
+	state    []byte
+	expireAt time.Time
+}
+
+// NewMemoryStore 创建有shards个分片的内存存储
+func NewMemoryStore(shards int) *MemoryStore {
+	if shards <= 0 {
+		shards = 1
+	}
+	s := &MemoryStore{shards: make([]*memoryShard, shards)}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{entries: make(map[string]memoryEntry)}
+	}
+	return s
+}
+
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	shard := s.shards[h.Sum32()%uint32(len(s.shards))]
+
+	now := time.Now()
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.Sub(shard.swept) > ttl {
+		for k, e := range shard.entries {
+			if now.After(e.expireAt) {
+				delete(shard.entries, k)
+			}
+		}
+		shard.swept = now
+	}
+
+	var state []byte
+	if e, ok := shard.entries[key]; ok && now.Before(e.expireAt) {
+		state = e.state
+	}
+	shard.entries[key] = memoryEntry{state: fn(state), expireAt: now.Add(ttl)}
+	return nil
+}
+
+// Len 当前保存的状态数（含尚未清理的过期状态）
+func (s *MemoryStore) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
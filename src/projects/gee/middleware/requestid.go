@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"learn-go/src/projects/gee"
+)
+
+// HeaderXRequestID 默认的请求ID请求头
+const HeaderXRequestID = "X-Request-ID"
+
+// RequestIDKey 请求ID在 gee.Context 中的键
+const RequestIDKey = "gee/request-id"
+
+// RequestIDConfig 请求ID配置
+type RequestIDConfig struct {
+	Header    string        // 读取和写回请求ID的请求头，默认X-Request-ID
+	Generator func() string // 请求未携带合法ID时的生成函数，默认生成32位十六进制随机串
+}
+
+type requestIDKey struct{}
+
+// RequestID 使用默认配置的请求ID中间件
+func RequestID() gee.HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{})
+}
+
+// RequestIDWithConfig 请求ID中间件
+// 沿用上游传入的请求ID，没有时生成新的ID；ID会写回请求头和响应头，并保存在 gee.Context 和请求的context.Context中
+func RequestIDWithConfig(config RequestIDConfig) gee.HandlerFunc {
+	if config.Header == "" {
+		config.Header = HeaderXRequestID
+	}
+	if config.Generator == nil {
+		config.Generator = generateID
+	}
+
+	return func(c *gee.Context) {
+		id := c.Req.Header.Get(config.Header)
+		if !validID(id) {
+			id = config.Generator()
+			c.Req.Header.Set(config.Header, id)
+		}
+		c.SetHeader(config.Header, id)
+		c.Set(RequestIDKey, id)
+		c.Req = c.Req.WithContext(context.WithValue(c.Req.Context(), requestIDKey{}, id))
+		c.Next()
+	}
+}
+
+// GetRequestID 获取当前请求的ID
+func GetRequestID(c *gee.Context) string {
+	if id := c.GetString(RequestIDKey); id != "" {
+		return id
+	}
+	return RequestIDFromContext(c.Req.Context())
+}
+
+// RequestIDFromContext 从context.Context中获取请求ID，便于传递给下游调用
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func generateID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// 拒绝过长或含有不可见字符的外部ID，防止日志注入
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
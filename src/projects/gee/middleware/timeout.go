@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"learn-go/src/projects/gee"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 超时后的响应体
+const timeoutBody = "503 SERVICE UNAVAILABLE: handler timeout\n"
+
+// Timeout 处理器超时中间件
+// 后续处理器在单独的goroutine中执行，输出先写入缓冲区；到达超时时间后立即响应503并丢弃缓冲的响应，
+// 之后处理器的写入返回 http.ErrHandlerTimeout。处理器可以通过 c.Req.Context() 感知超时并尽早返回，
+// 不感知时客户端同样会按时收到503，但中间件仍会等处理器返回后才结束，以免Context在使用中被回收
+// 处理器调用Flush或Hijack后响应直接写出，不再受超时控制
+func Timeout(timeout time.Duration) gee.HandlerFunc {
+	return func(c *gee.Context) {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), timeout)
+		defer cancel()
+		c.Req = c.Req.WithContext(ctx)
+
+		tw := &timeoutWriter{ResponseWriter: c.Writer, header: c.Writer.Header().Clone(), status: http.StatusOK}
+		c.Writer = tw
+		defer func() {
+			c.Writer = tw.ResponseWriter
+		}()
+
+		done := make(chan struct{})
+		var panicked any
+		go func() {
+			defer close(done)
+			defer func() {
+				panicked = recover()
+			}()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				tw.timeout()
+			}
+			<-done
+		}
+		if panicked != nil {
+			// 交给外层的Recovery处理
+			panic(panicked)
+		}
+		tw.commit()
+	}
+}
+
+// 缓冲处理器输出的ResponseWriter，处理器正常结束后才真正写出
+// 处理器与超时在不同的goroutine中，写入都需要持有mu
+type timeoutWriter struct {
+	gee.ResponseWriter
+	mu          sync.Mutex
+	header      http.Header  // 缓冲的响应头
+	status      int          // 缓冲的状态码
+	written     bool         // 处理器是否已发送响应头
+	buf         bytes.Buffer // 缓冲的响应体
+	passthrough bool         // 是否已切换为直接写出
+	timedOut    bool         // 是否已超时
+}
+
+// 超时时直接向底层ResponseWriter写出503
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return
+	}
+	w.passthrough, w.timedOut = true, true
+
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(timeoutBody)))
+	w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.ResponseWriter.Write([]byte(timeoutBody))
+	w.ResponseWriter.Flush()
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough && !w.timedOut {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.written = true
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	w.written = true
+	return w.buf.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return w.buf.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return w.ResponseWriter.Written()
+	}
+	return w.written
+}
+
+// 将缓冲的响应写到底层ResponseWriter
+func (w *timeoutWriter) commit() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.written {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
+
+func (w *timeoutWriter) Flush() {
+	w.commit()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.commit()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Hijack()
+}
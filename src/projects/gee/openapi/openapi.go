@@ -0,0 +1,224 @@
+// Package openapi 根据gee的路由和 gee.RouteDoc 生成OpenAPI 3文档
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"learn-go/src/projects/gee"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version 生成的文档遵循的OpenAPI版本
+const Version = "3.0.3"
+
+// Document OpenAPI文档
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+}
+
+// Info 文档的基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 同一路径下各请求方法的操作，键为小写的请求方法
+type PathItem map[string]*Operation
+
+// Operation 一个路由对应的操作
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter 路径或查询参数
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType 某种内容类型的结构
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components 可复用的结构定义
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Generate 根据已注册的路由生成文档，路径中的 :id 和 *path 转换为 {id} 和 {path}
+func Generate(e *gee.Engine, info Info) *Document {
+	return generate(e, info, func(gee.RouteInfo) bool { return false })
+}
+
+func generate(e *gee.Engine, info Info, skip func(gee.RouteInfo) bool) *Document {
+	doc := &Document{OpenAPI: Version, Info: info, Paths: make(map[string]PathItem)}
+	schemas := newSchemaRegistry()
+
+	for _, route := range e.Routes() {
+		if skip(route) {
+			continue
+		}
+		path, names := convertPath(route.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = newOperation(route, names, schemas)
+	}
+
+	if len(schemas.schemas) > 0 {
+		doc.Components = &Components{Schemas: schemas.schemas}
+	}
+	return doc
+}
+
+// Register 在path上提供文档，path以.yaml或.yml结尾时为YAML格式，否则按Accept协商JSON或YAML
+// 文档在第一次请求时生成，此后注册的路由不会出现在文档中；文档路由本身不会出现在文档中
+func Register(e *gee.Engine, path string, info Info) *gee.RouteInfo {
+	var (
+		once sync.Once
+		data []byte
+		yml  []byte
+		err  error
+		self *gee.RouteInfo
+	)
+	load := func() {
+		doc := generate(e, info, func(route gee.RouteInfo) bool {
+			return route.Method == self.Method && route.Path == self.Path
+		})
+		if data, err = json.MarshalIndent(doc, "", "  "); err == nil {
+			yml, err = toYAML(data)
+		}
+	}
+
+	yamlOnly := strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+	self = e.GET(path, func(c *gee.Context) {
+		once.Do(load)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, err)
+			return
+		}
+		if yamlOnly || c.NegotiateFormat(gee.MIMEJSON, gee.MIMEYAML) == gee.MIMEYAML {
+			c.SetHeader("Content-Type", gee.MIMEYAML)
+			c.Data(http.StatusOK, yml)
+			return
+		}
+		c.SetHeader("Content-Type", gee.MIMEJSON)
+		c.Data(http.StatusOK, data)
+	})
+	return self
+}
+
+// 将gee的路由转换为OpenAPI路径，并返回其中的参数名
+// 未命名的通配符 * 在OpenAPI中不能留空，以path命名
+func convertPath(pattern string) (string, []string) {
+	parts := strings.Split(pattern, "/")
+	var names []string
+	for i, part := range parts {
+		if part != "" && (part[0] == ':' || part[0] == '*') {
+			name := part[1:]
+			if name == "" {
+				name = "path"
+			}
+			names = append(names, name)
+			parts[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(parts, "/"), names
+}
+
+func newOperation(route gee.RouteInfo, pathParams []string, schemas *schemaRegistry) *Operation {
+	op := &Operation{OperationID: route.Name, Responses: make(map[string]*Response)}
+	doc := route.Doc
+	if doc == nil {
+		doc = &gee.RouteDoc{}
+	}
+	op.Summary, op.Description, op.Tags = doc.Summary, doc.Description, doc.Tags
+
+	declared := make(map[string]bool)
+	if doc.Request != nil {
+		inQuery := route.Method == http.MethodGet || route.Method == http.MethodHead || route.Method == http.MethodDelete
+		params, body := schemas.request(doc.Request, inQuery)
+		for _, p := range params {
+			if p.In == "path" {
+				declared[p.Name] = true
+			}
+		}
+		op.Parameters = params
+		if body != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{gee.MIMEJSON: {Schema: body}}}
+		}
+	}
+	// 请求结构体中没有声明的路径参数按字符串处理
+	for _, name := range pathParams {
+		if !declared[name] {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &Response{Description: http.StatusText(status)}
+	if doc.Response != nil {
+		resp.Content = map[string]*MediaType{gee.MIMEJSON: {Schema: schemas.schemaOf(doc.Response)}}
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+	return op
+}
+
+// 将JSON转换为YAML，保留字段顺序
+func toYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// 去掉JSON带来的流式风格和引号，由编码器在必要时重新加上引号
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"learn-go/src/projects/gee"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type user struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" binding:"required,min=2,max=20"`
+	Email     string    `json:"email,omitempty" binding:"regexp=^[^@]+@[^@]+$"`
+	Tags      []string  `json:"tags" binding:"max=5"`
+	Manager   *user     `json:"manager,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	password  string
+}
+
+type listUsers struct {
+	Page int    `form:"page" binding:"min=1"`
+	Sort string `form:"sort"`
+}
+
+type updateUser struct {
+	ID   int64  `uri:"id"`
+	Name string `json:"name" binding:"required"`
+}
+
+type userFilter struct {
+	Status string `form:"status" binding:"required"`
+}
+
+type searchUsers struct {
+	listUsers
+	Filter userFilter
+}
+
+type page[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+}
+
+func newEngine() *gee.Engine {
+	r := gee.New()
+	handler := func(c *gee.Context) {}
+	v1 := r.Group("/v1")
+	v1.GET("/users", handler).SetName("listUsers").SetDoc(gee.RouteDoc{
+		Summary: "List users", Tags: []string{"users"}, Request: listUsers{}, Response: []user{},
+	})
+	v1.POST("/users", handler).SetDoc(gee.RouteDoc{Request: user{}, Response: user{}, Status: http.StatusCreated})
+	v1.PUT("/users/:id", handler).SetDoc(gee.RouteDoc{Request: &updateUser{}, Response: user{}})
+	v1.GET("/search", handler).SetDoc(gee.RouteDoc{Request: searchUsers{}, Response: page[user]{}})
+	r.GET("/assets/*filepath", handler)
+	r.GET("/files/*", handler)
+	return r
+}
+
+func TestGenerate(t *testing.T) {
+	doc := Generate(newEngine(), Info{Title: "gee", Version: "1.0.0"})
+
+	list := doc.Paths["/v1/users"]["get"]
+	if list == nil || list.OperationID != "listUsers" || list.Summary != "List users" || len(list.Parameters) != 2 {
+		t.Fatalf("unexpected list operation %+v", list)
+	}
+	if p := list.Parameters[0]; p.Name != "page" || p.In != "query" || p.Required || *p.Schema.Minimum != 1 {
+		t.Fatalf("unexpected query parameter %+v", p)
+	}
+	if s := list.Responses["200"].Content[gee.MIMEJSON].Schema; s.Type != "array" || s.Items.Ref != "#/components/schemas/user" {
+		t.Fatalf("unexpected list response %+v", s)
+	}
+
+	create := doc.Paths["/v1/users"]["post"]
+	if create.RequestBody.Content[gee.MIMEJSON].Schema.Ref != "#/components/schemas/user" || create.Responses["201"] == nil {
+		t.Fatalf("unexpected create operation %+v", create)
+	}
+
+	update := doc.Paths["/v1/users/{id}"]["put"]
+	if p := update.Parameters[0]; len(update.Parameters) != 1 || p.Name != "id" || p.In != "path" || !p.Required || p.Schema.Format != "int64" {
+		t.Fatalf("unexpected path parameter %+v", update.Parameters)
+	}
+	if body := update.RequestBody.Content[gee.MIMEJSON].Schema; body.Properties["ID"] != nil || !reflect.DeepEqual(body.Required, []string{"name"}) {
+		t.Fatalf("path parameters should not be in the body: %+v", body)
+	}
+
+	if p := doc.Paths["/assets/{filepath}"]["get"].Parameters; len(p) != 1 || p[0].Name != "filepath" || p[0].Schema.Type != "string" {
+		t.Fatalf("wildcard should become a path parameter, got %+v", p)
+	}
+	if p := doc.Paths["/files/{path}"]["get"].Parameters; len(p) != 1 || p[0].Name != "path" || !p[0].Required {
+		t.Fatalf("unnamed wildcard should be named path, got %+v", p)
+	}
+
+	// 查询参数与 gee.Bind 一致，展开匿名和具名的无标签结构体
+	search := doc.Paths["/v1/search"]["get"]
+	var names []string
+	for _, p := range search.Parameters {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"page", "sort", "status"}) || !search.Parameters[2].Required {
+		t.Fatalf("nested structs should be expanded like Bind, got %v", names)
+	}
+	if ref := search.Responses["200"].Content[gee.MIMEJSON].Schema.Ref; ref != "#/components/schemas/page_user" || doc.Components.Schemas["page_user"] == nil {
+		t.Fatalf("generic type names should be sanitized, got %q", ref)
+	}
+
+	s := doc.Components.Schemas["user"]
+	name := s.Properties["name"]
+	if !reflect.DeepEqual(s.Required, []string{"name"}) || *name.MinLength != 2 || *name.MaxLength != 20 ||
+		s.Properties["email"].Pattern != "^[^@]+@[^@]+$" || *s.Properties["tags"].MaxItems != 5 ||
+		s.Properties["manager"].Ref != "#/components/schemas/user" || s.Properties["created_at"].Format != "date-time" ||
+		len(s.Properties) != 6 {
+		t.Fatalf("unexpected user schema %+v", s)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := newEngine()
+	Register(r, "/openapi.json", Info{Title: "gee", Version: "1.0.0"})
+	Register(r, "/openapi.yaml", Info{Title: "gee", Version: "1.0.0"})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || doc.OpenAPI != Version || doc.Paths["/openapi.json"] != nil {
+		t.Fatalf("unexpected json document %v %s", err, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+	var spec map[string]any
+	if err := yaml.Unmarshal(w.Body.Bytes(), &spec); err != nil || w.Header().Get("Content-Type") != gee.MIMEYAML ||
+		!strings.HasPrefix(w.Body.String(), "openapi: 3.0.3\n") || !strings.Contains(w.Body.String(), `"201":`) {
+		t.Fatalf("unexpected yaml document %v %s", err, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Header.Set("Accept", gee.MIMEYAML)
+	w = httptest.NewRecorder()
+	if r.ServeHTTP(w, req); w.Header().Get("Content-Type") != gee.MIMEYAML {
+		t.Fatalf("yaml should be negotiable, got %s", w.Header().Get("Content-Type"))
+	}
+}
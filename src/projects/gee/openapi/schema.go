@@ -0,0 +1,259 @@
+package openapi
+
+import (
+	"learn-go/src/projects/gee"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema 数据结构定义
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// 具名结构体注册到components中，通过$ref引用
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+func (r *schemaRegistry) schemaOf(v any) *Schema {
+	return r.typeSchema(reflect.TypeOf(v))
+}
+
+func (r *schemaRegistry) typeSchema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte按base64编码
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t, nil)
+		}
+		return &Schema{Ref: "#/components/schemas/" + r.register(t)}
+	}
+	// interface等任意类型
+	return &Schema{}
+}
+
+// 注册具名结构体，先占位再填充，以支持递归引用
+func (r *schemaRegistry) register(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	base := componentName(t)
+	name := base
+	for i := 2; r.schemas[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	r.names[t] = name
+	r.schemas[name] = &Schema{}
+	*r.schemas[name] = *r.structSchema(t, nil)
+	return name
+}
+
+// components中的名称只能包含字母、数字、_和-
+// 泛型实例的类型参数带有包路径，如 Page[learn-go/pkg.User]，只保留类型名并用_连接，得到Page_User
+func componentName(t reflect.Type) string {
+	var b []byte
+	word := 0 // 当前标识符在b中的起始位置
+	for _, c := range t.Name() {
+		switch {
+		case c == '/' || c == '.':
+			// 丢弃包路径
+			b = b[:word]
+		case c == '_' || c == '-' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			b = append(b, byte(c))
+		default:
+			if len(b) > 0 && b[len(b)-1] != '_' {
+				b = append(b, '_')
+			}
+			word = len(b)
+		}
+	}
+	return strings.TrimRight(string(b), "_")
+}
+
+// 按json标签生成结构体的schema，skip返回true的字段被忽略
+func (r *schemaRegistry) structSchema(t reflect.Type, skip func(sf reflect.StructField) bool) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	walkFields(t, "json", func(sf reflect.StructField, name string) {
+		if skip != nil && skip(sf) {
+			return
+		}
+		prop := r.typeSchema(sf.Type)
+		if applyRules(prop, sf) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	})
+	return s
+}
+
+// 根据请求结构体生成参数和请求体
+func (r *schemaRegistry) request(v any, inQuery bool) ([]*Parameter, *Schema) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		if inQuery {
+			return nil, nil
+		}
+		return nil, r.typeSchema(t)
+	}
+
+	var params []*Parameter
+	hasURI, hasBody := false, false
+	walkFields(t, "uri", func(sf reflect.StructField, name string) {
+		if _, ok := sf.Tag.Lookup("uri"); ok {
+			hasURI = true
+			params = append(params, r.parameter(sf, name, "path"))
+		}
+	})
+	walkFields(t, "form", func(sf reflect.StructField, name string) {
+		if _, ok := sf.Tag.Lookup("uri"); ok {
+			return
+		}
+		if inQuery {
+			params = append(params, r.parameter(sf, name, "query"))
+		} else {
+			hasBody = true
+		}
+	})
+
+	if !hasBody {
+		return params, nil
+	}
+	if !hasURI {
+		return params, r.typeSchema(t)
+	}
+	// 路径参数不属于请求体
+	return params, r.structSchema(t, func(sf reflect.StructField) bool {
+		_, ok := sf.Tag.Lookup("uri")
+		return ok
+	})
+}
+
+func (r *schemaRegistry) parameter(sf reflect.StructField, name, in string) *Parameter {
+	p := &Parameter{Name: name, In: in, Schema: r.typeSchema(sf.Type)}
+	p.Required = applyRules(p.Schema, sf) || in == "path"
+	return p
+}
+
+// 按指定标签遍历参与绑定的字段，字段名和嵌套结构体的展开规则与 gee.BindingField 一致
+func walkFields(t reflect.Type, tag string, fn func(sf reflect.StructField, name string)) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, inline := gee.BindingField(sf, tag)
+		if inline {
+			ft := sf.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			walkFields(ft, tag, fn)
+			continue
+		}
+		if name != "" {
+			fn(sf, name)
+		}
+	}
+}
+
+// 将binding标签中的校验规则写入schema，返回字段是否必填
+func applyRules(s *Schema, sf reflect.StructField) bool {
+	required := false
+	for _, rule := range gee.SplitBindingRules(sf.Tag.Get("binding")) {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil || s.Ref != "" {
+				continue
+			}
+			setLimit(s, name, limit)
+		case "regexp":
+			if s.Type == "string" {
+				s.Pattern = param
+			}
+		}
+	}
+	return required
+}
+
+// 数值限制取值范围，字符串限制长度，数组限制元素个数
+func setLimit(s *Schema, rule string, limit float64) {
+	n := int(limit)
+	var lower, upper **int
+	switch s.Type {
+	case "integer", "number":
+		if rule != "max" {
+			s.Minimum = &limit
+		}
+		if rule != "min" {
+			s.Maximum = &limit
+		}
+		return
+	case "string":
+		lower, upper = &s.MinLength, &s.MaxLength
+	case "array":
+		lower, upper = &s.MinItems, &s.MaxItems
+	default:
+		return
+	}
+	if rule != "max" {
+		*lower = &n
+	}
+	if rule != "min" {
+		*upper = &n
+	}
+}
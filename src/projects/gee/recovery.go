@@ -35,7 +35,10 @@ func Recovery() HandlerFunc {
 			if err := recover(); err != nil {
 				message := fmt.Sprintf("%s", err)
 				log.Printf("%s\n\n", trace(message))
-				c.Fail(http.StatusInternalServerError, errors.New(message))
+				// 响应头已经发送时无法再修改状态码
+				if !c.Writer.Written() {
+					c.Fail(http.StatusInternalServerError, errors.New(message))
+				}
 			}
 		}()
 
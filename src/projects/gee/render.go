@@ -0,0 +1,201 @@
+package gee
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 常用的响应类型
+const (
+	MIMEJSON     = "application/json"
+	MIMEXML      = "application/xml"
+	MIMEYAML     = "application/yaml"
+	MIMEHTML     = "text/html"
+	MIMEPlain    = "text/plain"
+	MIMEProtoBuf = "application/x-protobuf"
+)
+
+func (c *Context) XML(code int, obj any) {
+	c.SetHeader("Content-Type", MIMEXML)
+	c.Status(code)
+	if err := xml.NewEncoder(c.Writer).Encode(obj); err != nil {
+		http.Error(c.Writer, err.Error(), 500)
+	}
+}
+
+func (c *Context) YAML(code int, obj any) {
+	c.SetHeader("Content-Type", MIMEYAML)
+	c.Status(code)
+	if err := yaml.NewEncoder(c.Writer).Encode(obj); err != nil {
+		http.Error(c.Writer, err.Error(), 500)
+	}
+}
+
+// ProtoBuf 以protobuf二进制格式响应，先完成序列化，失败时不会写出部分响应
+func (c *Context) ProtoBuf(code int, msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		c.Fail(http.StatusInternalServerError, err)
+		return
+	}
+	c.SetHeader("Content-Type", MIMEProtoBuf)
+	c.Data(code, data)
+}
+
+// File 响应文件内容，ETag、Range、If-Modified-Since等条件请求由 http.ServeContent 处理
+func (c *Context) File(name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	defer f.Close()
+
+	d, err := f.Stat()
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	if d.IsDir() {
+		c.fileError(fs.ErrNotExist)
+		return
+	}
+	c.serveContent(d, f)
+}
+
+// FileAttachment 以附件形式下载文件，filename为空时使用文件本身的名字
+func (c *Context) FileAttachment(path, filename string) {
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+	// 非ASCII文件名按RFC 2231编码
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	c.SetHeader("Content-Disposition", disposition)
+	c.File(path)
+}
+
+// 将文件错误转换为对应的状态码，不向客户端暴露服务端路径
+func (c *Context) fileError(err error) {
+	c.Writer.Header().Del("Content-Disposition")
+	c.Writer.Header().Del("Cache-Control")
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		c.Fail(http.StatusNotFound, errors.New("404 page not found"))
+	case errors.Is(err, fs.ErrPermission):
+		c.Fail(http.StatusForbidden, errors.New("403 Forbidden"))
+	default:
+		c.Fail(http.StatusInternalServerError, errors.New("500 Internal Server Error"))
+	}
+}
+
+// Negotiate 内容协商配置
+type Negotiate struct {
+	Offered  []string // 可提供的响应类型，按优先级排列
+	Data     any      // 响应数据，协商为protobuf时必须实现proto.Message
+	HTMLName string   // 协商为HTML时使用的模板名
+}
+
+// Negotiate 根据Accept请求头选择响应格式，没有可接受的格式时响应406
+func (c *Context) Negotiate(code int, config Negotiate) {
+	switch c.NegotiateFormat(config.Offered...) {
+	case MIMEJSON:
+		c.JSON(code, config.Data)
+	case MIMEXML:
+		c.XML(code, config.Data)
+	case MIMEYAML:
+		c.YAML(code, config.Data)
+	case MIMEHTML:
+		c.HTML(code, config.HTMLName, config.Data)
+	case MIMEPlain:
+		c.String(code, "%v", config.Data)
+	case MIMEProtoBuf:
+		msg, ok := config.Data.(proto.Message)
+		if !ok {
+			c.Fail(http.StatusInternalServerError, fmt.Errorf("gee: %T is not a proto.Message", config.Data))
+			return
+		}
+		c.ProtoBuf(code, msg)
+	default:
+		c.Fail(http.StatusNotAcceptable, errors.New("the accepted formats are not offered by the server"))
+	}
+}
+
+// NegotiateFormat 从offered中选出客户端最能接受的类型
+// 取q值最高者，q值相同时按offered的顺序；未携带Accept时返回第一个，都不可接受时返回空串
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	accept := c.Req.Header.Get("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		if q := acceptQuality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Accept请求头中的一个媒体范围
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// 使用与offer匹配的最具体的媒体范围的q值，如 text/html 优先于 text/* 优先于 */*
+func acceptQuality(ranges []mediaRange, offer string) float64 {
+	typ, subtype, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
@@ -0,0 +1,134 @@
+package gee
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter 对http.ResponseWriter的包装
+// 状态码在首次写入响应体（或调用WriteHeaderNow）时才真正发送，因此中间件可以获取最终的状态码和响应大小
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+
+	// Status 响应的状态码
+	Status() int
+	// Size 已写入的响应体字节数
+	Size() int
+	// Written 响应头是否已经发送
+	Written() bool
+	// WriteHeaderNow 立即发送响应头
+	WriteHeaderNow()
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status  int  // 状态码
+	size    int  // 已写入的字节数
+	written bool // 响应头是否已发送
+}
+
+var _ ResponseWriter = (*responseWriter)(nil)
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = http.StatusOK
+	w.size = 0
+	w.written = false
+}
+
+// WriteHeader 只记录状态码，响应头发送后再次调用会被忽略
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.written {
+		w.written = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = w.ResponseWriter.Write(data)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) WriteString(s string) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.written
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 接管底层连接，之后不能再通过ResponseWriter写入
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: response writer does not implement http.Hijacker")
+	}
+	conn, brw, err := hj.Hijack()
+	if err == nil {
+		w.written = true
+	}
+	return conn, brw, err
+}
+
+// Push HTTP/2服务端推送，底层连接不支持时返回http.ErrNotSupported
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap 供http.ResponseController获取原始的ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ErrDetachedWriter 通过 Context.Copy 得到的副本不关联任何连接，写入时返回该错误
+var ErrDetachedWriter = errors.New("gee: write through a copied context")
+
+// 副本使用的ResponseWriter，响应头可以读写但不会发送，写入响应体返回ErrDetachedWriter
+type detachedWriter struct {
+	header http.Header
+}
+
+func (w *detachedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *detachedWriter) Write([]byte) (int, error) {
+	return 0, ErrDetachedWriter
+}
+
+func (w *detachedWriter) WriteHeader(int) {}
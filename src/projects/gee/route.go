@@ -0,0 +1,105 @@
+package gee
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// RouteInfo 已注册路由的信息
+type RouteInfo struct {
+	Method      string      // 请求方法
+	Path        string      // 注册的完整路由 如：/users/:id
+	Handler     string      // 处理器的函数名
+	HandlerFunc HandlerFunc // 处理器
+	Name        string      // 路由名，通过 RouteInfo.SetName 设置
+	Doc         *RouteDoc   // 文档信息，通过 RouteInfo.SetDoc 设置
+	engine      *Engine
+}
+
+// RouteDoc 路由的文档信息，用于生成OpenAPI文档
+type RouteDoc struct {
+	Summary     string   // 摘要
+	Description string   // 详细描述
+	Tags        []string // 分类标签
+	Request     any      // 请求结构体：uri标签生成路径参数，GET/HEAD/DELETE请求的form标签生成查询参数，其他请求按json标签生成请求体
+	Response    any      // 成功响应的结构体
+	Status      int      // 成功响应的状态码，默认200
+}
+
+// SetName 为路由命名，之后可通过 Engine.URL 按名称生成URL，名称重复时panic
+func (r *RouteInfo) SetName(name string) *RouteInfo {
+	e := r.engine
+	if _, ok := e.namedRoutes[name]; ok {
+		panic(fmt.Sprintf("gee: route name '%s' is already used", name))
+	}
+	if e.namedRoutes == nil {
+		e.namedRoutes = make(map[string]*RouteInfo)
+	}
+	r.Name = name
+	e.namedRoutes[name] = r
+	return r
+}
+
+// SetDoc 设置路由的文档信息
+func (r *RouteInfo) SetDoc(doc RouteDoc) *RouteInfo {
+	r.Doc = &doc
+	return r
+}
+
+// Routes 按注册顺序返回全部路由
+func (e *Engine) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(e.routes))
+	for _, route := range e.routes {
+		routes = append(routes, *route)
+	}
+	return routes
+}
+
+// URL 按路由名生成URL，params按顺序填充路由中的 :param 和 *wildcard
+// 如：路由 /users/:id/files/*path 的 e.URL("file", 1, "a/b.txt") 得到 /users/1/files/a/b.txt
+// 加载模板时会注册同名的模板函数 {{url "file" 1 "a/b.txt"}}
+func (e *Engine) URL(name string, params ...any) (string, error) {
+	route, ok := e.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("gee: route '%s' is not found", name)
+	}
+
+	var sb strings.Builder
+	i := 0
+	for _, part := range parsePattern(route.Path) {
+		sb.WriteByte('/')
+		if part[0] != ':' && part[0] != '*' {
+			sb.WriteString(part)
+			continue
+		}
+		if i >= len(params) {
+			return "", fmt.Errorf("gee: route '%s' (%s) needs more than %d params", name, route.Path, len(params))
+		}
+		value := fmt.Sprint(params[i])
+		i++
+		if part[0] == ':' {
+			sb.WriteString(url.PathEscape(value))
+			continue
+		}
+		// 通配参数可以包含多级路径，逐段转义
+		segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+		for j, segment := range segments {
+			segments[j] = url.PathEscape(segment)
+		}
+		sb.WriteString(strings.Join(segments, "/"))
+	}
+	if i != len(params) {
+		return "", fmt.Errorf("gee: route '%s' (%s) takes %d params, got %d", name, route.Path, i, len(params))
+	}
+	if sb.Len() == 0 {
+		return "/", nil
+	}
+	return sb.String(), nil
+}
+
+func nameOfFunction(f any) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
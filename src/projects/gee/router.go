@@ -2,19 +2,19 @@ package gee
 
 import (
 	"net/http"
+	"sort"
 	"strings"
 )
 
 // 路由器
 type router struct {
-	roots   map[string]*node
-	handles map[string]HandlerFunc
+	roots     map[string]*node // 每种请求方法对应一棵路由基数树
+	maxParams int              // 单条路由的最大参数个数，用于预分配参数切片
 }
 
 func newRouter() *router {
 	return &router{
-		roots:   make(map[string]*node),
-		handles: make(map[string]HandlerFunc),
+		roots: make(map[string]*node),
 	}
 }
 
@@ -34,57 +34,126 @@ func parsePattern(pattern string) []string {
 	return parts
 }
 
-func buildKey(sep string, elems ...string) string {
-	return strings.Join(elems[:], sep)
+// 规范化路径：合并多余的斜杠并去掉末尾的斜杠，常见的路径不会产生内存分配
+func cleanPath(path string) string {
+	if strings.Contains(path, "//") || path == "" || path[0] != '/' {
+		return "/" + strings.Join(parsePattern(path), "/")
+	}
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		return path[:len(path)-1]
+	}
+	return path
 }
 
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
+func (r *router) addRoute(method string, pattern string, handlers []HandlerFunc) {
 	parts := parsePattern(pattern)
 
 	if _, ok := r.roots[method]; !ok {
 		r.roots[method] = &node{}
 	}
 
-	r.roots[method].insert(pattern, parts, 0)
-	r.handles[buildKey("-", method, pattern)] = handler
+	r.roots[method].insert(pattern, "/"+strings.Join(parts, "/"), handlers)
+
+	params := 0
+	for _, part := range parts {
+		if part[0] == ':' || len(part) > 1 && part[0] == '*' {
+			params++
+		}
+	}
+	if params > r.maxParams {
+		r.maxParams = params
+	}
 }
 
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path)
-	params := make(map[string]string)
+// 匹配路由，参数追加到ps中
+func (r *router) lookup(method string, path string, ps *Params) *node {
 	root, ok := r.roots[method]
-
 	if !ok {
-		return nil, nil
+		return nil
 	}
+	return root.search(cleanPath(path), ps)
+}
 
-	n := root.search(searchParts, 0)
-	if n != nil {
-		parts := parsePattern(n.pattern)
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-			}
-		}
+func (r *router) getRoute(method string, path string) (*node, Params) {
+	params := make(Params, 0, r.maxParams)
+	if n := r.lookup(method, path, &params); n != nil {
 		return n, params
 	}
 	return nil, nil
 }
 
+// 获取路径已注册的全部请求方法
+func (r *router) allowed(path string) []string {
+	methods := make([]string, 0, len(r.roots)+2)
+	for method := range r.roots {
+		if method == http.MethodOptions {
+			continue
+		}
+		if n, _ := r.getRoute(method, path); n != nil {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return methods
+	}
+
+	// GET路由同样可以响应HEAD请求，OPTIONS总是可用
+	if contains(methods, http.MethodGet) && !contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	methods = append(methods, http.MethodOptions)
+	sort.Strings(methods)
+	return methods
+}
+
+func contains(elems []string, v string) bool {
+	for _, elem := range elems {
+		if elem == v {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
+	n := r.lookup(c.Method, c.Path, &c.Params)
+	if n == nil && c.Method == http.MethodHead {
+		// HEAD请求回退到GET处理器
+		n = r.lookup(http.MethodGet, c.Path, &c.Params)
+	}
 
 	if n != nil {
-		c.Params = params
-		c.handlers = append(c.handlers, r.handles[buildKey("-", c.Method, n.pattern)])
+		// 复制到Context自己的切片中，避免后续append修改节点上的处理链
+		c.handlers = append(c.handlers, n.handlers...)
+		c.Next()
+		return
+	}
+
+	// 未匹配到路由时，执行路径所属分组的中间件
+	c.handlers = append(c.handlers, c.engine.matchGroup(c.Path).combineHandlers(nil)...)
+	if allow := r.allowed(c.Path); len(allow) > 0 {
+		c.SetHeader("Allow", strings.Join(allow, ", "))
+		if c.Method == http.MethodOptions {
+			c.handlers = append(c.handlers, func(c *Context) {
+				c.Status(http.StatusNoContent)
+			})
+		} else {
+			// 路径存在但请求方法不匹配
+			c.handlers = append(c.handlers, c.engine.noMethod...)
+		}
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+		c.handlers = append(c.handlers, c.engine.noRoute...)
 	}
 
 	c.Next()
 }
+
+// 默认的404处理器
+func notFound(c *Context) {
+	c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+}
+
+// 默认的405处理器
+func methodNotAllowed(c *Context) {
+	c.String(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s %s\n", c.Method, c.Path)
+}
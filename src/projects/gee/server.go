@@ -0,0 +1,100 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 发送WebSocket关闭帧的最长等待时间
+const wsCloseTimeout = time.Second
+
+// 创建并记录http服务，以便Shutdown时统一关闭
+func (e *Engine) newServer(addr string) *http.Server {
+	var handler http.Handler = e
+	if e.UseH2C {
+		// 允许不经过TLS的HTTP/2明文连接
+		handler = h2c.NewHandler(e, &http2.Server{IdleTimeout: e.IdleTimeout})
+	}
+
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  e.ReadTimeout,
+		WriteTimeout: e.WriteTimeout,
+		IdleTimeout:  e.IdleTimeout,
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.servers = append(e.servers, srv)
+	return srv
+}
+
+// 调用Shutdown后服务正常退出，不视为错误
+func serveErr(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Run 启动http服务
+func (e *Engine) Run(addr string) (err error) {
+	return serveErr(e.newServer(addr).ListenAndServe())
+}
+
+// RunTLS 启动https服务，客户端支持时自动协商HTTP/2
+func (e *Engine) RunTLS(addr string, certFile string, keyFile string) (err error) {
+	return serveErr(e.newServer(addr).ListenAndServeTLS(certFile, keyFile))
+}
+
+// RunListener 在指定的监听器上启动http服务
+func (e *Engine) RunListener(listener net.Listener) (err error) {
+	return serveErr(e.newServer(listener.Addr().String()).Serve(listener))
+}
+
+// Shutdown 优雅关闭所有已启动的服务：停止接收新连接，并等待处理中的请求完成或ctx超时
+// 已升级的WebSocket连接不受http服务管理，会收到CloseGoingAway关闭帧
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	servers := e.servers
+	e.servers = nil
+	conns := make([]*WSConn, 0, len(e.wsConns))
+	for ws := range e.wsConns {
+		conns = append(conns, ws)
+	}
+	e.mu.Unlock()
+
+	// 不持有锁并行发送关闭帧，写超时避免不读数据的客户端阻塞关闭流程
+	deadline := time.Now().Add(wsCloseTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	var wg sync.WaitGroup
+	for _, ws := range conns {
+		wg.Add(1)
+		go func(ws *WSConn) {
+			defer wg.Done()
+			_ = ws.conn.SetWriteDeadline(deadline)
+			_ = ws.WriteClose(CloseGoingAway, "server shutdown")
+			if d, ok := ctx.Deadline(); ok {
+				_ = ws.SetReadDeadline(d)
+			}
+		}(ws)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,137 @@
+package sessions
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidCookie = errors.New("sessions: invalid cookie")
+	ErrExpiredCookie = errors.New("sessions: expired cookie")
+)
+
+func init() {
+	// 闪存消息以[]any保存
+	gob.Register([]any{})
+}
+
+// 使用一组密钥签名并加密cookie值
+// 第一组密钥用于写入，全部密钥都可用于读取，轮换时将新密钥放在最前面
+type codec struct {
+	keys []codecKey
+}
+
+type codecKey struct {
+	hashKey []byte      // HMAC-SHA256签名密钥
+	aead    cipher.AEAD // AES-GCM加密，为nil时只签名不加密
+}
+
+// keyPairs依次为签名密钥和加密密钥，加密密钥长度须为16、24或32字节，传nil表示不加密
+func newCodec(keyPairs ...[]byte) *codec {
+	if len(keyPairs) == 0 {
+		panic("sessions: at least one hash key is required")
+	}
+	c := &codec{}
+	for i := 0; i < len(keyPairs); i += 2 {
+		key := codecKey{hashKey: keyPairs[i]}
+		if len(key.hashKey) == 0 {
+			panic("sessions: hash key must not be empty")
+		}
+		if i+1 < len(keyPairs) && keyPairs[i+1] != nil {
+			block, err := aes.NewCipher(keyPairs[i+1])
+			if err != nil {
+				panic(fmt.Sprintf("sessions: invalid block key: %v", err))
+			}
+			key.aead, _ = cipher.NewGCM(block)
+		}
+		c.keys = append(c.keys, key)
+	}
+	return c
+}
+
+// 编码格式：base64url(时间戳 | 密文 | HMAC(name | 时间戳 | 密文))
+func (c *codec) encode(name string, value []byte) (string, error) {
+	key := c.keys[0]
+	payload := value
+	if key.aead != nil {
+		nonce := make([]byte, key.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = key.aead.Seal(nonce, nonce, value, []byte(name))
+	}
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, time.Now().Unix())
+	buf.Write(payload)
+	buf.Write(mac(key.hashKey, name, buf.Bytes()))
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// 依次尝试每组密钥，maxAge大于0时拒绝签发时间早于maxAge之前的值
+func (c *codec) decode(name, value string, maxAge time.Duration) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) < 8+sha256.Size {
+		return nil, ErrInvalidCookie
+	}
+	msg, sum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+
+	for _, key := range c.keys {
+		if !hmac.Equal(mac(key.hashKey, name, msg), sum) {
+			continue
+		}
+		issued := time.Unix(int64(binary.BigEndian.Uint64(msg)), 0)
+		if maxAge > 0 && time.Since(issued) > maxAge {
+			return nil, ErrExpiredCookie
+		}
+		payload := msg[8:]
+		if key.aead == nil {
+			return payload, nil
+		}
+		size := key.aead.NonceSize()
+		if len(payload) < size {
+			return nil, ErrInvalidCookie
+		}
+		plain, err := key.aead.Open(nil, payload[:size], payload[size:], []byte(name))
+		if err != nil {
+			return nil, ErrInvalidCookie
+		}
+		return plain, nil
+	}
+	return nil, ErrInvalidCookie
+}
+
+// cookie名参与签名，防止把一个cookie的值挪用到另一个cookie上
+func mac(key []byte, name string, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+func encodeValues(values map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValues(data []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
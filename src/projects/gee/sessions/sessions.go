@@ -0,0 +1,180 @@
+// Package sessions gee的会话中间件，提供基于cookie和基于内存的会话存储
+package sessions
+
+import (
+	"learn-go/src/projects/gee"
+	"net/http"
+	"time"
+)
+
+// 会话保存在 Context.Keys 中的键
+const sessionKey = "gee/sessions"
+
+// 闪存消息在Values中的键
+const flashKey = "_flash"
+
+// Options 会话cookie的属性
+type Options struct {
+	Path     string
+	Domain   string
+	MaxAge   time.Duration // 会话有效期，为0时是浏览器会话cookie，服务端存储仍按7天清理
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultOptions 默认的cookie属性
+var DefaultOptions = Options{
+	Path:     "/",
+	MaxAge:   7 * 24 * time.Hour,
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// 生成cookie
+func (o Options) cookie(name, value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		Secure:   o.Secure,
+		HttpOnly: o.HttpOnly,
+		SameSite: o.SameSite,
+	}
+	if o.MaxAge > 0 {
+		cookie.MaxAge = int(o.MaxAge / time.Second)
+		cookie.Expires = time.Now().Add(o.MaxAge)
+	}
+	return cookie
+}
+
+// 删除cookie
+func (o Options) expired(name string) *http.Cookie {
+	cookie := o.cookie(name, "")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(1, 0)
+	return cookie
+}
+
+// Store 会话存储
+// 自定义类型的值需要先通过 gob.Register 注册
+type Store interface {
+	// Load 读取请求携带的会话，cookie不存在时返回新会话，cookie无效时同时返回新会话和错误
+	Load(c *gee.Context, name string) (*Session, error)
+	// Save 保存会话并写出Set-Cookie响应头
+	Save(c *gee.Context, s *Session) error
+}
+
+// Session 一个会话
+type Session struct {
+	ID     string         // 服务端存储的会话ID，cookie存储中为空
+	Name   string         // cookie名
+	Values map[string]any // 会话数据
+	IsNew  bool           // 请求是否没有携带有效的会话
+
+	previousID  string // Regenerate之前的会话ID，保存时删除
+	destroyed   bool
+	regenerated bool
+	ctx         *gee.Context
+	store       Store
+}
+
+// NewSession 创建空会话，供 Store 的实现使用
+func NewSession(store Store, name string) *Session {
+	return &Session{Name: name, Values: make(map[string]any), IsNew: true, store: store}
+}
+
+func (s *Session) Get(key string) any {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key string, value any) {
+	s.Values[key] = value
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+}
+
+// Clear 清空会话数据
+func (s *Session) Clear() {
+	for key := range s.Values {
+		delete(s.Values, key)
+	}
+}
+
+// AddFlash 添加闪存消息，消息在下一次调用Flashes时被取出并删除
+func (s *Session) AddFlash(value any) {
+	flashes, _ := s.Values[flashKey].([]any)
+	s.Values[flashKey] = append(flashes, value)
+}
+
+// Flashes 取出并删除全部闪存消息，需要再次Save才会生效
+func (s *Session) Flashes() []any {
+	flashes, _ := s.Values[flashKey].([]any)
+	delete(s.Values, flashKey)
+	return flashes
+}
+
+// Regenerate 更换会话ID并保留数据，登录等权限变化时调用以防止会话固定攻击
+func (s *Session) Regenerate() {
+	if s.ID != "" && s.previousID == "" {
+		s.previousID = s.ID
+	}
+	s.ID = ""
+	s.regenerated = true
+}
+
+// Regenerated 是否调用过Regenerate，供 Store 的实现使用
+func (s *Session) Regenerated() (previousID string, ok bool) {
+	return s.previousID, s.regenerated
+}
+
+// Destroy 清空会话，保存时删除服务端数据并使cookie过期
+func (s *Session) Destroy() {
+	s.Clear()
+	s.destroyed = true
+}
+
+// Destroyed 是否调用过Destroy，供 Store 的实现使用
+func (s *Session) Destroyed() bool {
+	return s.destroyed
+}
+
+// Save 保存会话，需要在写入响应体之前调用
+func (s *Session) Save() error {
+	return s.store.Save(s.ctx, s)
+}
+
+// Sessions 会话中间件，会话在第一次调用 Default 时才从store中读取
+func Sessions(name string, store Store) gee.HandlerFunc {
+	return func(c *gee.Context) {
+		c.Set(sessionKey, &lazySession{name: name, store: store})
+		c.Next()
+	}
+}
+
+type lazySession struct {
+	name    string
+	store   Store
+	session *Session
+}
+
+// Default 获取当前请求的会话，未注册 Sessions 中间件时panic
+// 读取cookie出错（被篡改、密钥已轮换掉、已过期）时返回新会话，错误记录在 Context.Errors 中
+func Default(c *gee.Context) *Session {
+	lazy := c.MustGet(sessionKey).(*lazySession)
+	if lazy.session == nil {
+		s, err := lazy.store.Load(c, lazy.name)
+		if err != nil {
+			_ = c.Error(err)
+		}
+		if s == nil {
+			s = NewSession(lazy.store, lazy.name)
+		}
+		s.ctx = c
+		lazy.session = s
+	}
+	return lazy.session
+}
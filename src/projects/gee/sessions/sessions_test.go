@@ -0,0 +1,122 @@
+package sessions
+
+import (
+	"learn-go/src/projects/gee"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	hashKey  = []byte("0123456789abcdef0123456789abcdef")
+	blockKey = []byte("fedcba9876543210")
+)
+
+func newEngine(store Store) *gee.Engine {
+	r := gee.New()
+	r.Use(Sessions("gee_session", store))
+	r.POST("/login", func(c *gee.Context) {
+		s := Default(c)
+		s.Regenerate()
+		s.Set("user", c.Query("user"))
+		s.AddFlash("welcome")
+		if err := s.Save(); err != nil {
+			c.Fail(http.StatusInternalServerError, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/me", func(c *gee.Context) {
+		s := Default(c)
+		flashes := s.Flashes()
+		_ = s.Save()
+		c.String(http.StatusOK, "%v %v %d", s.Get("user"), flashes, len(c.Errors))
+	})
+	r.POST("/logout", func(c *gee.Context) {
+		s := Default(c)
+		s.Destroy()
+		_ = s.Save()
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+// 发送请求并携带cookie
+func do(r *gee.Engine, method, target string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCookieStore(t *testing.T) {
+	store := NewCookieStore(hashKey, blockKey)
+	r := newEngine(store)
+
+	w := do(r, http.MethodPost, "/login?user=geektutu", nil)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].MaxAge != 7*24*3600 || strings.Contains(cookies[0].Value, "geektutu") {
+		t.Fatalf("unexpected session cookie %+v", cookies)
+	}
+
+	if w = do(r, http.MethodGet, "/me", cookies); w.Body.String() != "geektutu [welcome] 0" {
+		t.Fatalf("unexpected session %q", w.Body.String())
+	}
+	// 闪存消息只能读取一次
+	if w = do(r, http.MethodGet, "/me", w.Result().Cookies()); w.Body.String() != "geektutu [] 0" {
+		t.Fatalf("flashes should be consumed, got %q", w.Body.String())
+	}
+
+	tampered := *cookies[0]
+	tampered.Value = tampered.Value[:len(tampered.Value)-2] + "AA"
+	if w = do(r, http.MethodGet, "/me", []*http.Cookie{&tampered}); w.Body.String() != "<nil> [] 1" {
+		t.Fatalf("tampered cookie should be rejected, got %q", w.Body.String())
+	}
+
+	// 新密钥写入，旧密钥仍可读取
+	rotated := newEngine(NewCookieStore([]byte("new-hash-key"), []byte("0123456789abcdef"), hashKey, blockKey))
+	if w = do(rotated, http.MethodGet, "/me", cookies); w.Body.String() != "geektutu [welcome] 0" {
+		t.Fatalf("old keys should still decode, got %q", w.Body.String())
+	}
+	if w = do(newEngine(NewCookieStore([]byte("new-hash-key"))), http.MethodGet, "/me", cookies); w.Body.String() != "<nil> [] 1" {
+		t.Fatalf("removed keys should no longer decode, got %q", w.Body.String())
+	}
+
+	w = do(r, http.MethodPost, "/logout", cookies)
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge != -1 {
+		t.Fatalf("logout should expire the cookie, got %+v", c)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(hashKey)
+	r := newEngine(store)
+
+	first := do(r, http.MethodPost, "/login?user=jack", nil).Result().Cookies()
+	if w := do(r, http.MethodGet, "/me", first); w.Body.String() != "jack [welcome] 0" {
+		t.Fatalf("unexpected session %q", w.Body.String())
+	}
+
+	// 再次登录更换会话ID，旧ID失效
+	second := do(r, http.MethodPost, "/login?user=rose", first).Result().Cookies()
+	if second[0].Value == first[0].Value || store.Len() != 1 {
+		t.Fatalf("session id should be regenerated, %d sessions left", store.Len())
+	}
+	if w := do(r, http.MethodGet, "/me", first); w.Body.String() != "<nil> [] 0" {
+		t.Fatalf("old session id should be invalid, got %q", w.Body.String())
+	}
+	if w := do(r, http.MethodGet, "/me", second); w.Body.String() != "rose [welcome] 0" {
+		t.Fatalf("unexpected session %q", w.Body.String())
+	}
+
+	// 携带无效ID的请求保存时得到了新的匿名会话
+	before := store.Len()
+	do(r, http.MethodPost, "/logout", second)
+	if store.Len() != before-1 {
+		t.Fatalf("logout should delete the server side session, %d left", store.Len())
+	}
+}
@@ -0,0 +1,180 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"learn-go/src/projects/gee"
+	"sync"
+	"time"
+)
+
+// ErrCookieTooLong 编码后的会话超出浏览器对单个cookie的大小限制
+var ErrCookieTooLong = errors.New("sessions: encoded cookie value exceeds 4096 bytes")
+
+// 服务端存储中没有过期时间的会话的保留时长
+const defaultServerTTL = 7 * 24 * time.Hour
+
+// CookieStore 将会话数据签名、加密后整体保存在cookie中，服务端无状态
+type CookieStore struct {
+	Options Options
+	codec   *codec
+}
+
+// NewCookieStore keyPairs依次为签名密钥和加密密钥（16、24或32字节），可以传入多组以轮换密钥：
+// 第一组用于写入，其余的只用于读取旧cookie
+func NewCookieStore(keyPairs ...[]byte) *CookieStore {
+	return &CookieStore{Options: DefaultOptions, codec: newCodec(keyPairs...)}
+}
+
+func (st *CookieStore) Load(c *gee.Context, name string) (*Session, error) {
+	s := NewSession(st, name)
+	value, err := c.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	data, err := st.codec.decode(name, value, st.Options.MaxAge)
+	if err != nil {
+		return s, err
+	}
+	values, err := decodeValues(data)
+	if err != nil {
+		return s, err
+	}
+	s.Values, s.IsNew = values, false
+	return s, nil
+}
+
+func (st *CookieStore) Save(c *gee.Context, s *Session) error {
+	if s.Destroyed() {
+		c.SetCookie(st.Options.expired(s.Name))
+		return nil
+	}
+	data, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	value, err := st.codec.encode(s.Name, data)
+	if err != nil {
+		return err
+	}
+	if len(value) > 4096 {
+		return ErrCookieTooLong
+	}
+	c.SetCookie(st.Options.cookie(s.Name, value))
+	return nil
+}
+
+// MemoryStore 会话数据保存在进程内存中，cookie中只保存签名后的会话ID
+type MemoryStore struct {
+	Options  Options
+	codec    *codec
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	swept    time.Time // 上次清理过期会话的时间
+}
+
+type memorySession struct {
+	data     []byte
+	expireAt time.Time
+}
+
+// NewMemoryStore keyPairs的含义与 NewCookieStore 相同
+func NewMemoryStore(keyPairs ...[]byte) *MemoryStore {
+	return &MemoryStore{
+		Options:  DefaultOptions,
+		codec:    newCodec(keyPairs...),
+		sessions: make(map[string]memorySession),
+	}
+}
+
+func (st *MemoryStore) Load(c *gee.Context, name string) (*Session, error) {
+	s := NewSession(st, name)
+	value, err := c.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	id, err := st.codec.decode(name, value, st.Options.MaxAge)
+	if err != nil {
+		return s, err
+	}
+
+	st.mu.Lock()
+	entry, ok := st.sessions[string(id)]
+	st.mu.Unlock()
+	if !ok || time.Now().After(entry.expireAt) {
+		return s, nil
+	}
+	values, err := decodeValues(entry.data)
+	if err != nil {
+		return s, err
+	}
+	s.ID, s.Values, s.IsNew = string(id), values, false
+	return s, nil
+}
+
+func (st *MemoryStore) Save(c *gee.Context, s *Session) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sweep()
+
+	if previousID, ok := s.Regenerated(); ok {
+		delete(st.sessions, previousID)
+	}
+	if s.Destroyed() {
+		delete(st.sessions, s.ID)
+		c.SetCookie(st.Options.expired(s.Name))
+		return nil
+	}
+
+	data, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	if s.ID == "" {
+		if s.ID, err = newSessionID(); err != nil {
+			return err
+		}
+	}
+	value, err := st.codec.encode(s.Name, []byte(s.ID))
+	if err != nil {
+		return err
+	}
+
+	ttl := st.Options.MaxAge
+	if ttl <= 0 {
+		ttl = defaultServerTTL
+	}
+	st.sessions[s.ID] = memorySession{data: data, expireAt: time.Now().Add(ttl)}
+	c.SetCookie(st.Options.cookie(s.Name, value))
+	return nil
+}
+
+// Len 当前保存的会话数
+func (st *MemoryStore) Len() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.sessions)
+}
+
+// 每分钟至多清理一次过期会话
+func (st *MemoryStore) sweep() {
+	now := time.Now()
+	if now.Sub(st.swept) < time.Minute {
+		return
+	}
+	for id, entry := range st.sessions {
+		if now.After(entry.expireAt) {
+			delete(st.sessions, id)
+		}
+	}
+	st.swept = now
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,143 @@
+package gee
+
+import (
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StaticConfig 静态文件服务配置
+type StaticConfig struct {
+	Index  string        // 请求目录时返回的文件，默认index.html
+	SPA    bool          // 单页应用模式，不存在的路径返回根目录下的Index，交给前端路由处理
+	MaxAge time.Duration // Cache-Control的max-age，为0时要求客户端每次都用ETag校验
+}
+
+// Static 提供本地目录下的静态文件
+func (g *RouterGroup) Static(relativePath string, root string) {
+	g.StaticFS(relativePath, http.Dir(root))
+}
+
+// StaticFS 提供任意文件系统中的静态文件，embed.FS等fs.FS可通过 http.FS 转换
+func (g *RouterGroup) StaticFS(relativePath string, fsys http.FileSystem) {
+	g.StaticFSWithConfig(relativePath, fsys, StaticConfig{})
+}
+
+// StaticFSWithConfig 按配置提供静态文件
+func (g *RouterGroup) StaticFSWithConfig(relativePath string, fsys http.FileSystem, config StaticConfig) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("gee: URL parameters can not be used when serving static files")
+	}
+	handler := g.createStaticHandler(fsys, config)
+	g.GET(path.Join(relativePath, "/*filepath"), handler)
+	// *filepath不匹配空路径，前缀本身需要单独注册，否则访问 /assets、/assets/ 或SPA的 / 时返回404
+	g.GET(path.Join("/", relativePath), handler)
+}
+
+// StaticFile 将单个文件注册为路由 如：r.StaticFile("/favicon.ico", "./static/favicon.ico")
+func (g *RouterGroup) StaticFile(relativePath, filepath string) {
+	g.StaticFileWithConfig(relativePath, filepath, StaticConfig{})
+}
+
+// StaticFileWithConfig 按配置将单个文件注册为路由，配置中只有MaxAge生效
+func (g *RouterGroup) StaticFileWithConfig(relativePath, filepath string, config StaticConfig) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("gee: URL parameters can not be used when serving a static file")
+	}
+	cacheControl := config.cacheControl()
+	g.GET(relativePath, func(c *Context) {
+		c.SetHeader("Cache-Control", cacheControl)
+		c.File(filepath)
+	})
+}
+
+// 根据MaxAge生成Cache-Control
+func (config StaticConfig) cacheControl() string {
+	if config.MaxAge > 0 {
+		return "public, max-age=" + strconv.Itoa(int(config.MaxAge/time.Second))
+	}
+	return "no-cache"
+}
+
+func (g *RouterGroup) createStaticHandler(fsys http.FileSystem, config StaticConfig) HandlerFunc {
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+	cacheControl := config.cacheControl()
+
+	return func(c *Context) {
+		name := path.Clean("/" + c.Param("filepath"))
+		f, d, err := openStatic(fsys, name, config.Index)
+		control := cacheControl
+		if err != nil && config.SPA && errors.Is(err, fs.ErrNotExist) {
+			// 入口页面可能随发布变化，不使用长期缓存
+			f, d, err = openStatic(fsys, "/"+config.Index, config.Index)
+			control = "no-cache"
+		}
+		if err != nil {
+			c.fileError(err)
+			return
+		}
+		defer f.Close()
+
+		c.SetHeader("Cache-Control", control)
+		c.serveContent(d, f)
+	}
+}
+
+// 打开静态文件，目录则打开其中的index文件，不提供目录列表
+func openStatic(fsys http.FileSystem, name, index string) (http.File, fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	if !d.IsDir() {
+		return f, d, nil
+	}
+
+	_ = f.Close()
+	if f, err = fsys.Open(path.Join(name, index)); err != nil {
+		return nil, nil, err
+	}
+	if d, err = f.Stat(); err != nil || d.IsDir() {
+		_ = f.Close()
+		return nil, nil, fs.ErrNotExist
+	}
+	return f, d, nil
+}
+
+// 设置ETag后交给 http.ServeContent，由其处理If-None-Match、If-Modified-Since和Range
+func (c *Context) serveContent(d fs.FileInfo, content io.ReadSeeker) {
+	if etag, err := fileETag(d, content); err == nil {
+		c.SetHeader("ETag", etag)
+	}
+	http.ServeContent(c.Writer, c.Req, d.Name(), d.ModTime(), content)
+}
+
+// 根据修改时间和大小生成弱ETag；embed.FS中的文件没有修改时间，改用内容摘要
+func fileETag(d fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !d.ModTime().IsZero() {
+		return `W/"` + strconv.FormatInt(d.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(d.Size(), 16) + `"`, nil
+	}
+
+	h := fnv.New64a()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`, nil
+}
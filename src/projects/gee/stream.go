@@ -0,0 +1,128 @@
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Stream 分块流式响应，每次调用step后立即flush
+// step返回false时结束，客户端断开连接时也会结束，返回值表示是否因客户端断开而结束
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// 首次推送事件前写入Server-Sent Events的响应头
+func (c *Context) sseHeader() {
+	if c.Writer.Written() {
+		return
+	}
+	c.SetHeader("Content-Type", "text/event-stream")
+	c.SetHeader("Cache-Control", "no-cache")
+	c.SetHeader("Connection", "keep-alive")
+	// 禁止nginx等反向代理缓冲事件流
+	c.SetHeader("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+// SSEvent 推送一条Server-Sent Events事件，name为空时客户端按message事件处理
+// 字符串和字节切片原样发送（多行会拆分为多个data字段），其余类型编码为JSON
+func (c *Context) SSEvent(name string, data any) {
+	c.sseHeader()
+
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			panic(fmt.Sprintf("gee: encoding SSE data: %v", err))
+		}
+		payload = string(b)
+	}
+
+	var sb strings.Builder
+	// 事件名中的换行会注入额外的字段，直接去掉
+	if name = sseLineBreaks.Replace(name); name != "" {
+		sb.WriteString("event: " + name + "\n")
+	}
+	for _, line := range sseLines(payload) {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+
+	_, _ = io.WriteString(c.Writer, sb.String())
+	c.Writer.Flush()
+}
+
+// SSEComment 推送一条注释，客户端会忽略注释，通常定时发送以防止空闲连接被代理断开
+func (c *Context) SSEComment(comment string) {
+	c.sseHeader()
+	var sb strings.Builder
+	for _, line := range sseLines(comment) {
+		sb.WriteString(": " + line + "\n")
+	}
+	sb.WriteString("\n")
+	_, _ = io.WriteString(c.Writer, sb.String())
+	c.Writer.Flush()
+}
+
+// SSE 一条Server-Sent Events事件
+type SSE struct {
+	Event string // 事件名，为空时客户端按message事件处理
+	Data  any    // 事件数据，编码方式同 SSEvent
+}
+
+// SSEStream 依次推送events中的事件，直到events关闭或客户端断开，返回值表示是否因客户端断开而结束
+// keepAlive大于0时，超过keepAlive没有事件就推送一条注释，防止空闲连接被代理断开
+func (c *Context) SSEStream(events <-chan SSE, keepAlive time.Duration) bool {
+	c.sseHeader()
+	c.Writer.Flush()
+
+	var tick <-chan time.Time
+	if keepAlive > 0 {
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(e.Event, e.Data)
+		case <-tick:
+			c.SSEComment("keep-alive")
+		}
+	}
+}
+
+var sseLineBreaks = strings.NewReplacer("\r\n", "", "\r", "", "\n", "")
+
+// 按SSE的规则拆分行，\r\n、\r和\n都是换行
+func sseLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.ReplaceAll(s, "\r", "\n"), "\n")
+}
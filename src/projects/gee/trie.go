@@ -1,70 +1,209 @@
 package gee
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
-// 路由前缀树
+// Param 路径参数
+type Param struct {
+	Key   string // 参数名 如：lang
+	Value string // 参数值 如：go
+}
+
+// Params 按路由中出现的顺序保存的路径参数
+type Params []Param
+
+// Get 获取参数值
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName 获取参数值，不存在时返回空字符串
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
+type nodeType uint8
+
+const (
+	static   nodeType = iota // 静态节点
+	param                    // 参数节点 如：:lang
+	catchAll                 // 通配节点 如：*filepath
+)
+
+// 路由基数树（压缩前缀树）
+// 静态子节点按公共前缀压缩，参数名在注册时保存在节点上，匹配时无需再次解析路由
+// 匹配优先级：静态 > 参数(:) > 通配(*)，与注册顺序无关
 type node struct {
-	pattern  string  // 待匹配的路径 如：/p/:lang
-	part     string  // 路径中的匹配项 如：:lang
-	children []*node // 子节点 如：[doc, tutorial, intro]
-	isWild   bool    // 是否模糊匹配 part含“:”或“*”时为true
+	path      string        // 静态节点为压缩后的路径片段 如：/p/，通配节点为 :lang 或 *filepath
+	nType     nodeType      // 节点类型
+	indices   string        // 静态子节点路径的首字节 与children一一对应
+	children  []*node       // 静态子节点
+	wildChild *node         // 参数子节点 同级至多一个
+	anyChild  *node         // 通配子节点 同级至多一个
+	pattern   string        // 注册的完整路由 如：/p/:lang 仅在可匹配的节点上非空
+	handlers  []HandlerFunc // 路由的完整处理链：所属分组的中间件、路由中间件和处理器
 }
 
-func (n *node) matchChild(part string) *node {
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			return child
+// 查找路径中下一个通配符（位于片段开头的 : 或 *）的位置
+func wildcardIndex(path string) int {
+	for i := 1; i < len(path); i++ {
+		if (path[i] == ':' || path[i] == '*') && path[i-1] == '/' {
+			return i
 		}
 	}
-	return nil
+	return len(path)
 }
 
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// 返回子树中任意一个已注册的路由，用于冲突提示
+func (n *node) anyPattern() string {
+	if n.pattern != "" {
+		return n.pattern
+	}
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
+		if p := child.anyPattern(); p != "" {
+			return p
+		}
+	}
+	for _, child := range []*node{n.wildChild, n.anyChild} {
+		if child != nil {
+			if p := child.anyPattern(); p != "" {
+				return p
+			}
 		}
 	}
-	return nodes
+	return ""
 }
 
-func (n *node) insert(pattern string, parts []string, height int) {
-	if len(parts) == height {
+// 在第i个字节处将静态节点一分为二
+func (n *node) split(i int) {
+	child := *n
+	child.path = n.path[i:]
+	*n = node{
+		path:     n.path[:i],
+		indices:  child.path[:1],
+		children: []*node{&child},
+	}
+}
+
+// 插入路由，path为去掉当前节点后剩余的待插入路径
+// 存在歧义（重复注册、同级出现名称不同的同类通配符）时panic
+func (n *node) insert(pattern string, path string, handlers []HandlerFunc) {
+	if path == "" {
+		if n.pattern != "" {
+			panic(fmt.Sprintf("gee: route '%s' conflicts with existing route '%s'", pattern, n.pattern))
+		}
 		n.pattern = pattern
+		n.handlers = handlers
 		return
 	}
 
-	part := parts[height]
-	child := n.matchChild(part)
+	// 只有位于片段开头的 : 和 * 才是通配符
+	if n.nType == static && strings.HasSuffix(n.path, "/") {
+		switch path[0] {
+		case ':':
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			n.wildChild = n.wildcard(n.wildChild, param, path[:end], pattern)
+			n.wildChild.insert(pattern, path[end:], handlers)
+			return
+		case '*':
+			n.anyChild = n.wildcard(n.anyChild, catchAll, path, pattern)
+			n.anyChild.insert(pattern, "", handlers)
+			return
+		}
+	}
 
-	if child == nil {
-		child = &node{
-			part:   part,
-			isWild: part[0] == ':' || part[0] == '*',
+	end := wildcardIndex(path)
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == path[0] {
+			child := n.children[i]
+			l := commonPrefix(child.path, path[:end])
+			if l < len(child.path) {
+				child.split(l)
+			}
+			child.insert(pattern, path[l:], handlers)
+			return
 		}
-		n.children = append(n.children, child)
 	}
 
-	child.insert(pattern, parts, height+1)
+	child := &node{path: path[:end]}
+	n.indices += path[:1]
+	n.children = append(n.children, child)
+	child.insert(pattern, path[end:], handlers)
+}
+
+// 获取或创建通配子节点，同级已存在名称不同的同类通配符时panic
+func (n *node) wildcard(child *node, typ nodeType, part string, pattern string) *node {
+	if child == nil {
+		return &node{path: part, nType: typ}
+	}
+	if child.path != part {
+		panic(fmt.Sprintf("gee: wildcard '%s' in route '%s' conflicts with '%s' in existing route '%s'",
+			part, pattern, child.path, child.anyPattern()))
+	}
+	return child
 }
 
-func (n *node) search(parts []string, height int) *node {
-	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+// 匹配路由，path为去掉当前节点后剩余的待匹配路径，匹配到的参数追加到ps中
+// 静态子节点匹配失败时回溯尝试参数和通配子节点，整个过程不分配内存
+func (n *node) search(path string, ps *Params) *node {
+	if path == "" {
 		if n.pattern == "" {
 			return nil
 		}
 		return n
 	}
 
-	part := parts[height]
-	children := n.matchChildren(part)
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == path[0] {
+			child := n.children[i]
+			if strings.HasPrefix(path, child.path) {
+				if result := child.search(path[len(child.path):], ps); result != nil {
+					return result
+				}
+			}
+			break
+		}
+	}
+
+	if child := n.wildChild; child != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			size := len(*ps)
+			*ps = append(*ps, Param{Key: child.path[1:], Value: path[:end]})
+			if result := child.search(path[end:], ps); result != nil {
+				return result
+			}
+			*ps = (*ps)[:size]
+		}
+	}
 
-	for _, child := range children {
-		result := child.search(parts, height+1)
-		if result != nil {
-			return result
+	if child := n.anyChild; child != nil {
+		if name := child.path[1:]; name != "" {
+			*ps = append(*ps, Param{Key: name, Value: path})
 		}
+		return child
 	}
 
 	return nil
@@ -0,0 +1,341 @@
+package gee
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RFC 6455 握手时用于计算 Sec-WebSocket-Accept 的固定GUID
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket 消息类型（帧的opcode）
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// WebSocket 关闭码
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	CloseMessageTooBig    = 1009
+)
+
+// 默认的最大消息大小
+const defaultMaxMessageSize = 32 << 20
+
+// CloseError 收到关闭帧或因协议错误关闭连接时返回的错误
+type CloseError struct {
+	Code int    // 关闭码
+	Text string // 关闭原因
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// WSConn WebSocket连接
+type WSConn struct {
+	conn           net.Conn
+	br             *bufio.Reader
+	wmu            sync.Mutex // 保证帧的写入不会交错
+	closeSent      bool       // 是否已发送关闭帧
+	ctx            *Context   // 发起升级的请求上下文
+	MaxMessageSize int64      // 单条消息（含分片）的最大字节数
+}
+
+// Context 返回发起升级的请求上下文，仅在处理器执行期间有效
+func (ws *WSConn) Context() *Context {
+	return ws.ctx
+}
+
+// RemoteAddr 客户端地址
+func (ws *WSConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SetReadDeadline 设置读超时
+func (ws *WSConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// WS 注册WebSocket路由，分组中间件在升级之前执行，handler返回后连接自动关闭
+func (g *RouterGroup) WS(pattern string, handler func(ws *WSConn)) {
+	g.GET(pattern, func(c *Context) {
+		ws, err := upgrade(c)
+		if err != nil {
+			log.Printf("[WebSocket] upgrade failed: %v", err)
+			return
+		}
+
+		c.engine.trackWS(ws, true)
+		defer c.engine.trackWS(ws, false)
+		defer ws.Close()
+
+		handler(ws)
+	})
+}
+
+// 检查header中是否包含指定的token（逗号分隔，不区分大小写）
+func headerContains(h http.Header, key string, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 默认只接受同源请求，防止跨站WebSocket劫持
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+// 完成RFC 6455握手并接管底层连接
+func upgrade(c *Context) (*WSConn, error) {
+	req := c.Req
+	if !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") {
+		c.String(http.StatusBadRequest, "400 BAD REQUEST: not a websocket handshake\n")
+		return nil, errors.New("missing upgrade headers")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		c.String(http.StatusUpgradeRequired, "426 UPGRADE REQUIRED\n")
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		c.String(http.StatusBadRequest, "400 BAD REQUEST: invalid Sec-WebSocket-Key\n")
+		return nil, errors.New("invalid Sec-WebSocket-Key")
+	}
+	if !sameOrigin(req) {
+		c.String(http.StatusForbidden, "403 FORBIDDEN: cross-origin websocket\n")
+		return nil, errors.New("origin not allowed")
+	}
+
+	c.Status(http.StatusSwitchingProtocols)
+	conn, brw, err := c.Writer.Hijack()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "500 INTERNAL SERVER ERROR\n")
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	_, err = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// 握手完成后不再受http服务的读写超时限制
+	_ = conn.SetDeadline(time.Time{})
+	return &WSConn{conn: conn, br: brw.Reader, ctx: c, MaxMessageSize: defaultMaxMessageSize}, nil
+}
+
+// 读取一帧，返回fin标记、opcode和去掩码后的数据
+func (ws *WSConn) readFrame() (bool, int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := head[0]&0x80 != 0
+	opcode := int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, ws.fail(CloseProtocolError, "reserved bits set")
+	}
+	// 客户端发送的帧必须带掩码
+	if !masked {
+		return false, 0, nil, ws.fail(CloseProtocolError, "unmasked client frame")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if opcode >= CloseMessage && (!fin || length > 125) {
+		return false, 0, nil, ws.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length < 0 || length > ws.MaxMessageSize {
+		return false, 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// ReadMessage 读取一条完整的数据消息（自动拼接分片）
+// 期间收到的ping会自动回复pong，收到关闭帧时回复关闭帧并返回 *CloseError
+func (ws *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := ws.WriteMessage(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			ce := &CloseError{Code: CloseNoStatusReceived}
+			if len(payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(payload))
+				ce.Text = string(payload[2:])
+			}
+			code := ce.Code
+			if code == CloseNoStatusReceived {
+				code = CloseNormalClosure
+			}
+			_ = ws.WriteClose(code, "")
+			return 0, nil, ce
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = opcode
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+
+		if int64(len(data)+len(payload)) > ws.MaxMessageSize {
+			return 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+		}
+		data = append(data, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(data) {
+				return 0, nil, ws.fail(CloseInvalidPayload, "invalid utf-8 text")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// WriteMessage 发送一条消息，服务端发送的帧不带掩码
+func (ws *WSConn) WriteMessage(messageType int, data []byte) error {
+	if messageType >= CloseMessage && len(data) > 125 {
+		return errors.New("websocket: control frame payload too large")
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | byte(messageType)
+	switch n := len(data); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	if ws.closeSent {
+		return errors.New("websocket: close sent")
+	}
+	if messageType == CloseMessage {
+		ws.closeSent = true
+	}
+	_, err := (&net.Buffers{header, data}).WriteTo(ws.conn)
+	return err
+}
+
+// WriteClose 发送关闭帧，之后不能再发送任何消息
+func (ws *WSConn) WriteClose(code int, text string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return ws.WriteMessage(CloseMessage, append(payload, text...))
+}
+
+// 因协议错误关闭连接
+func (ws *WSConn) fail(code int, text string) error {
+	_ = ws.WriteClose(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+// Close 发送正常关闭帧（如未发送过）并关闭底层连接
+func (ws *WSConn) Close() error {
+	_ = ws.WriteClose(CloseNormalClosure, "")
+	return ws.conn.Close()
+}
+
+// 记录活跃的WebSocket连接，Shutdown时通知其关闭
+func (e *Engine) trackWS(ws *WSConn, add bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if add {
+		if e.wsConns == nil {
+			e.wsConns = make(map[*WSConn]struct{})
+		}
+		e.wsConns[ws] = struct{}{}
+	} else {
+		delete(e.wsConns, ws)
+	}
+}
@@ -0,0 +1,222 @@
+package arc
+
+import (
+	"container/list"
+	"learn-go/src/projects/geecache/lru"
+	"time"
+)
+
+// Value 与 lru.Value 相同，便于不同淘汰策略互相替换
+type Value = lru.Value
+
+// Cache ARC（Adaptive Replacement Cache）缓存，按字节数计算容量
+// t1保存只访问过一次的缓存，t2保存访问过多次的缓存，b1、b2分别记录最近从t1、t2淘汰的key（幽灵缓存，只有key和大小）
+// 命中b1说明t1太小，命中b2说明t2太小，据此自适应调整t1的目标大小p，扫描只会冲刷t1而不会影响t2
+type Cache struct {
+	maxBytes  int64                         // 最大内存大小，为0时不淘汰
+	p         int64                         // t1的目标大小
+	t1, t2    segment                       // 实际缓存
+	b1, b2    segment                       // 幽灵缓存
+	cache     map[string]*list.Element      // 数据字典，包含幽灵缓存
+	OnEvicted func(key string, value Value) // 回调函数（淘汰、过期和删除时都会调用）
+	now       func() time.Time              // 时钟，便于测试
+}
+
+// 一个LRU链表及其占用的字节数
+type segment struct {
+	ll     list.List
+	nBytes int64
+}
+
+type entry struct {
+	key      string
+	value    Value // 幽灵缓存中为nil
+	size     int64 // key和value占用的内存大小，幽灵缓存中保留被淘汰时的大小
+	expireAt time.Time
+	seg      *segment // 所在的链表
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		cache:     make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+		now:       time.Now,
+	}
+}
+
+// Get 获取缓存，命中后移入t2，已过期的缓存在此时惰性删除
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	elem, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if e.seg == &c.b1 || e.seg == &c.b2 {
+		return nil, false
+	}
+	if e.expired(c.now()) {
+		c.removeElement(elem, true)
+		return nil, false
+	}
+	c.cache[key] = c.move(elem, &c.t2)
+	return e.value, true
+}
+
+// Add 新增/更新永不过期的缓存
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 新增/更新缓存，expireAt为零值时永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expireAt time.Time) {
+	size := int64(len(key)) + int64(value.Len())
+	elem, ok := c.cache[key]
+	if !ok {
+		// 全新的key进入t1
+		c.cache[key] = c.push(&c.t1, &entry{key: key, value: value, size: size, expireAt: expireAt})
+		c.evict(false)
+		return
+	}
+
+	e := elem.Value.(*entry)
+	switch e.seg {
+	case &c.b1:
+		// 最近从t1淘汰的key又被访问，增大t1
+		c.p = min(c.maxBytes, c.p+max(c.b2.nBytes/max(c.b1.nBytes, 1), 1)*size)
+	case &c.b2:
+		// 最近从t2淘汰的key又被访问，增大t2
+		c.p = max(0, c.p-max(c.b1.nBytes/max(c.b2.nBytes, 1), 1)*size)
+	default:
+		// 更新操作
+		c.resize(e, size)
+		e.value, e.expireAt = value, expireAt
+		c.cache[key] = c.move(elem, &c.t2)
+		c.evict(false)
+		return
+	}
+	inB2 := e.seg == &c.b2
+	e.seg.ll.Remove(elem)
+	e.seg.nBytes -= e.size
+	c.cache[key] = c.push(&c.t2, &entry{key: key, value: value, size: size, expireAt: expireAt})
+	c.evict(inB2)
+}
+
+// RemoveOldest 按ARC的规则淘汰一个缓存
+func (c *Cache) RemoveOldest() {
+	c.replace(false)
+}
+
+// Remove 删除指定的缓存，返回缓存是否存在，删除的缓存不会进入幽灵缓存
+func (c *Cache) Remove(key string) bool {
+	elem, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	e := elem.Value.(*entry)
+	ghost := e.seg == &c.b1 || e.seg == &c.b2
+	c.removeElement(elem, !ghost)
+	return !ghost
+}
+
+// RemoveExpired 删除全部已过期的缓存，返回删除的数量
+func (c *Cache) RemoveExpired() int {
+	now, n := c.now(), 0
+	for _, seg := range []*segment{&c.t1, &c.t2} {
+		for elem := seg.ll.Back(); elem != nil; {
+			prev := elem.Prev()
+			if elem.Value.(*entry).expired(now) {
+				c.removeElement(elem, true)
+				n++
+			}
+			elem = prev
+		}
+	}
+	return n
+}
+
+// Len 实际缓存的数量，不含幽灵缓存
+func (c *Cache) Len() int {
+	return c.t1.ll.Len() + c.t2.ll.Len()
+}
+
+// Bytes 实际缓存占用的内存大小，不含幽灵缓存
+func (c *Cache) Bytes() int64 {
+	return c.t1.nBytes + c.t2.nBytes
+}
+
+// 超出容量时淘汰实际缓存，并限制幽灵缓存的大小
+func (c *Cache) evict(inB2 bool) {
+	if c.maxBytes == 0 {
+		return
+	}
+	for c.Bytes() > c.maxBytes {
+		c.replace(inB2)
+	}
+	for c.t1.nBytes+c.b1.nBytes > c.maxBytes && c.b1.ll.Len() > 0 {
+		c.removeElement(c.b1.ll.Back(), false)
+	}
+	for c.Bytes()+c.b1.nBytes+c.b2.nBytes > 2*c.maxBytes && c.b2.ll.Len() > 0 {
+		c.removeElement(c.b2.ll.Back(), false)
+	}
+}
+
+// t1超出目标大小时从t1淘汰到b1，否则从t2淘汰到b2
+func (c *Cache) replace(inB2 bool) {
+	if c.t1.ll.Len() > 0 && (c.t1.nBytes > c.p || (inB2 && c.t1.nBytes == c.p) || c.t2.ll.Len() == 0) {
+		c.demote(&c.t1, &c.b1)
+	} else if c.t2.ll.Len() > 0 {
+		c.demote(&c.t2, &c.b2)
+	}
+}
+
+// 淘汰from中最久未访问的缓存，只在幽灵缓存to中保留key
+func (c *Cache) demote(from, to *segment) {
+	elem := from.ll.Back()
+	e := elem.Value.(*entry)
+	value := e.value
+	from.ll.Remove(elem)
+	from.nBytes -= e.size
+	c.cache[e.key] = c.push(to, &entry{key: e.key, size: e.size})
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, value)
+	}
+}
+
+func (c *Cache) push(seg *segment, e *entry) *list.Element {
+	e.seg = seg
+	seg.nBytes += e.size
+	return seg.ll.PushFront(e)
+}
+
+// 移动到seg的头部，返回新的链表节点
+func (c *Cache) move(elem *list.Element, seg *segment) *list.Element {
+	e := elem.Value.(*entry)
+	if e.seg == seg {
+		seg.ll.MoveToFront(elem)
+		return elem
+	}
+	e.seg.ll.Remove(elem)
+	e.seg.nBytes -= e.size
+	return c.push(seg, e)
+}
+
+func (c *Cache) resize(e *entry, size int64) {
+	e.seg.nBytes += size - e.size
+	e.size = size
+}
+
+// notify为true时调用淘汰回调，幽灵缓存没有值，不调用
+func (c *Cache) removeElement(elem *list.Element, notify bool) {
+	e := elem.Value.(*entry)
+	e.seg.ll.Remove(elem)
+	e.seg.nBytes -= e.size
+	delete(c.cache, e.key)
+	if notify && c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
@@ -0,0 +1,78 @@
+package arc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (s String) Len() int {
+	return len(s)
+}
+
+func TestScanResistant(t *testing.T) {
+	arc := New(int64(40), nil)
+	for _, key := range []string{"h1", "h2", "h3", "h4", "h5"} {
+		arc.Add(key, String("vv"))
+		arc.Get(key)
+	}
+	// 只访问一次的key只会在t1中互相淘汰
+	for i := 0; i < 100; i++ {
+		arc.Add(fmt.Sprintf("s%d", i), String("v"))
+	}
+	for _, key := range []string{"h1", "h2", "h3", "h4", "h5"} {
+		if _, ok := arc.Get(key); !ok {
+			t.Fatalf("hot key %s should survive the scan", key)
+		}
+	}
+	if arc.Bytes() > 40 {
+		t.Fatalf("cache exceeds its budget: %d bytes", arc.Bytes())
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	var evicted []string
+	arc := New(int64(8), func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	arc.Add("k1", String("v1"))
+	arc.Add("k2", String("v2"))
+	arc.Get("k2")
+	arc.Add("k3", String("v3"))
+	if len(evicted) != 1 || evicted[0] != "k1" || arc.t1.ll.Len() != 1 || arc.b1.ll.Len() != 1 {
+		t.Fatalf("k1 should be evicted into b1, evicted=%v", evicted)
+	}
+	if _, ok := arc.Get("k1"); ok {
+		t.Fatalf("ghost entries are not readable")
+	}
+
+	// 命中b1，增大t1的目标大小，k1直接进入t2，t1未超出目标大小，从t2淘汰k2
+	arc.Add("k1", String("v1"))
+	if arc.p != 4 || arc.t2.ll.Len() != 1 || arc.b2.ll.Len() != 1 || evicted[1] != "k2" {
+		t.Fatalf("ghost hit in b1 should grow p, p=%d", arc.p)
+	}
+	if !arc.Remove("k1") || arc.Remove("k1") || arc.Len() != 1 || arc.Bytes() != 4 {
+		t.Fatalf("Remove k1 failed, len=%d bytes=%d", arc.Len(), arc.Bytes())
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	arc := New(int64(0), nil)
+	arc.now = func() time.Time { return now }
+	arc.AddWithExpire("k1", String("v1"), now.Add(time.Second))
+	arc.AddWithExpire("k2", String("v2"), now.Add(time.Minute))
+	arc.Add("k3", String("v3"))
+	arc.Get("k2")
+
+	now = now.Add(time.Second)
+	if _, ok := arc.Get("k1"); ok || arc.Len() != 2 {
+		t.Fatalf("k1 should expire on access")
+	}
+	now = now.Add(time.Hour)
+	if n := arc.RemoveExpired(); n != 1 || arc.Len() != 1 || arc.Bytes() != 4 {
+		t.Fatalf("RemoveExpired removed %d, len=%d", n, arc.Len())
+	}
+}
@@ -1,34 +1,191 @@
 package geecache
 
 import (
-	"learn-go/src/projects/geecache/lru"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// 每个分片至少分到的缓存大小，缓存较小时减少分片数，避免单个分片过小而频繁淘汰
+const minShardBytes = 64 << 10
+
+// 并发缓存结构，key按哈希分布到多个分片，每个分片有独立的锁和容量，不同分片的读写互不阻塞
 type cache struct {
+	shards          []*cacheShard // 分片
+	cleanupInterval time.Duration // 后台清理过期缓存的间隔，小于等于0时只惰性删除
+	mu              sync.Mutex    // 保护后台清理的状态
+	stop            chan struct{} // 关闭时停止后台清理
+	closed          bool          // 关闭后不再启动后台清理
+	started         atomic.Bool   // 后台清理已启动或已关闭，add时无需再加锁检查
+}
+
+type cacheShard struct {
 	mu         sync.Mutex // 互斥锁
-	lru        *lru.Cache // LRU
-	cacheBytes int64      // 缓存大小
+	policy     Policy     // 淘汰策略
+	newPolicy  PolicyFunc // 创建淘汰策略
+	cacheBytes int64      // 分片的缓存大小
+	gets, hits int64      // 查询和命中次数
 }
 
-func (c *cache) add(key string, value ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
+// cacheBytes为0时不限制大小，shards为分片数的上限，newPolicy为nil时使用LRU
+func newCache(cacheBytes int64, shards int, newPolicy PolicyFunc, cleanupInterval time.Duration) *cache {
+	if shards <= 0 {
+		shards = 1
+	}
+	if cacheBytes > 0 {
+		shards = int(max(1, min(int64(shards), cacheBytes/minShardBytes)))
+	}
+	if newPolicy == nil {
+		newPolicy = LRU
+	}
+
+	c := &cache{shards: make([]*cacheShard, shards), cleanupInterval: cleanupInterval}
+	for i := range c.shards {
+		// 余数分给前面的分片，总容量与cacheBytes相同
+		shardBytes := cacheBytes / int64(shards)
+		if int64(i) < cacheBytes%int64(shards) {
+			shardBytes++
+		}
+		c.shards[i] = &cacheShard{cacheBytes: shardBytes, newPolicy: newPolicy}
+	}
+	return c
+}
+
+// FNV-1a哈希选择分片
+func (c *cache) shard(key string) *cacheShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+// expireAt为零值时永不过期
+func (c *cache) add(key string, value ByteView, expireAt time.Time) {
+	s := c.shard(key)
+	s.mu.Lock()
+	if s.policy == nil {
 		// 延迟初始化
-		c.lru = lru.New(c.cacheBytes, nil)
+		s.policy = s.newPolicy(s.cacheBytes)
+	}
+	s.policy.AddWithExpire(key, value, expireAt)
+	s.mu.Unlock()
+
+	if !expireAt.IsZero() && c.cleanupInterval > 0 {
+		c.startCleanup()
+	}
+}
+
+// 持有分片锁执行读-改-写，fn以当前值（不存在或已过期时ok为false）计算新值，keep为false时删除key
+func (c *cache) update(key string, expireAt time.Time, fn func(old ByteView, ok bool) (value ByteView, keep bool)) {
+	s := c.shard(key)
+	s.mu.Lock()
+	if s.policy == nil {
+		s.policy = s.newPolicy(s.cacheBytes)
+	}
+	var old ByteView
+	v, ok := s.policy.Get(key)
+	if ok {
+		old = v.(ByteView)
+	}
+	if value, keep := fn(old, ok); keep {
+		s.policy.AddWithExpire(key, value, expireAt)
+	} else if ok {
+		s.policy.Remove(key)
+	}
+	s.mu.Unlock()
+
+	if !expireAt.IsZero() && c.cleanupInterval > 0 {
+		c.startCleanup()
 	}
-	c.lru.Add(key, value)
 }
 
 func (c *cache) get(key string) (value ByteView, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	if s.policy == nil {
 		return
 	}
-	if v, ok := c.lru.Get(key); ok {
+	if v, ok := s.policy.Get(key); ok {
+		s.hits++
 		return v.(ByteView), ok
 	}
 	return
 }
+
+func (c *cache) remove(key string) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy != nil {
+		s.policy.Remove(key)
+	}
+}
+
+// 各分片统计数据之和
+func (c *cache) stats() CacheStats {
+	var stats CacheStats
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.Gets += s.gets
+		stats.Hits += s.hits
+		if s.policy != nil {
+			stats.Bytes += s.policy.Bytes()
+			stats.Items += int64(s.policy.Len())
+		}
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// 出现会过期的缓存后才启动后台清理
+func (c *cache) startCleanup() {
+	if c.started.Load() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop == nil && !c.closed {
+		c.stop = make(chan struct{})
+		go c.cleanup(c.stop)
+	}
+	c.started.Store(true)
+}
+
+// 定期删除过期缓存，避免不再被访问的缓存一直占用内存，每次只锁住一个分片
+func (c *cache) cleanup(stop chan struct{}) {
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, s := range c.shards {
+				s.mu.Lock()
+				if s.policy != nil {
+					s.policy.RemoveExpired()
+				}
+				s.mu.Unlock()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// 停止后台清理
+func (c *cache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.started.Store(true)
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
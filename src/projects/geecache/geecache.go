@@ -1,11 +1,14 @@
 package geecache
 
 import (
+	"context"
 	"fmt"
 	"learn-go/src/projects/geecache/geecachepb"
 	"learn-go/src/projects/geecache/singleflight"
 	"log"
+	"math/rand"
 	"sync"
+	"time"
 )
 
 type Getter interface {
@@ -20,13 +23,52 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// TTLGetter 同时返回缓存有效期的回调，有效期为0时使用缓存组的默认有效期，为负数时永不过期
+type TTLGetter interface {
+	GetWithTTL(key string) ([]byte, time.Duration, error)
+}
+
+// TTLGetterFunc 返回有效期的回调函数，同时实现了 Getter 和 TTLGetter
+type TTLGetterFunc func(key string) ([]byte, time.Duration, error)
+
+func (f TTLGetterFunc) Get(key string) ([]byte, error) {
+	bytes, _, err := f(key)
+	return bytes, err
+}
+
+func (f TTLGetterFunc) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	return f(key)
+}
+
+// GroupOption 缓存组的可选配置
+type GroupOption struct {
+	TTL             time.Duration // 缓存的默认有效期，为0时永不过期
+	CleanupInterval time.Duration // 后台清理过期缓存的间隔，为负数时只在访问时惰性删除
+	HotCacheBytes   int64         // hotCache的大小，默认为mainCache的1/8（mainCache不限大小时不启用），为负数时不启用hotCache
+	Policy          PolicyFunc    // 淘汰策略，默认为LRU
+	Shards          int           // 缓存分片数的上限，实际分片数还受缓存大小限制，每个分片至少64KB
+}
+
+// 未传入配置时使用的默认配置，每个Group都会得到一份副本
+var defaultGroupOption = GroupOption{
+	CleanupInterval: time.Minute,
+	Shards:          16,
+}
+
+// 从peer节点获取的值有1/hotCacheRatio的概率放入hotCache
+const hotCacheRatio = 10
+
 // Group 缓存的命名空间
 type Group struct {
 	name      string              // 名称
 	getter    Getter              // 未命中时获取源数据的回调
-	mainCache cache               // 并发缓存结构
+	mainCache *cache              // 并发缓存结构，保存本节点负责的key
+	hotCache  *cache              // 保存从peer节点获取的热点key，避免每次都经过网络
+	hotRatio  int                 // 放入hotCache的概率为1/hotRatio，为0时不启用hotCache
 	peers     PeerPicker          // 具备选择peer节点的能力
 	loader    *singleflight.Group // 防止缓存击穿
+	ttl       time.Duration       // 缓存的默认有效期
+	stats     groupStats          // 统计数据
 }
 
 var (
@@ -34,10 +76,22 @@ var (
 	groups = make(map[string]*Group)
 )
 
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...*GroupOption) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
+	opt := parseOptions(opts...)
+	hotCacheBytes, hotRatio := opt.HotCacheBytes, hotCacheRatio
+	if hotCacheBytes == 0 {
+		hotCacheBytes = cacheBytes / 8
+		if hotCacheBytes == 0 {
+			// mainCache不限大小（或过小）时没有合适的默认值，0会被当作不限大小，因此不启用
+			hotCacheBytes = -1
+		}
+	}
+	if hotCacheBytes < 0 {
+		hotCacheBytes, hotRatio = 0, 0
+	}
 
 	mu.Lock()
 	defer mu.Unlock()
@@ -45,13 +99,39 @@ func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
 	group := &Group{
 		name:      name,
 		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
+		mainCache: newCache(cacheBytes, opt.Shards, opt.Policy, opt.CleanupInterval),
+		hotCache:  newCache(hotCacheBytes, opt.Shards, opt.Policy, opt.CleanupInterval),
+		hotRatio:  hotRatio,
 		loader:    &singleflight.Group{},
+		ttl:       opt.TTL,
+	}
+	if old, ok := groups[name]; ok {
+		// 同名的缓存组被替换，停止旧缓存组的后台清理
+		old.mainCache.close()
+		old.hotCache.close()
 	}
 	groups[name] = group
 	return group
 }
 
+func parseOptions(opts ...*GroupOption) *GroupOption {
+	if len(opts) == 0 || opts[0] == nil {
+		opt := defaultGroupOption
+		return &opt
+	}
+	if len(opts) != 1 {
+		panic("number of option is more than 1")
+	}
+	opt := *opts[0]
+	if opt.CleanupInterval == 0 {
+		opt.CleanupInterval = defaultGroupOption.CleanupInterval
+	}
+	if opt.Shards == 0 {
+		opt.Shards = defaultGroupOption.Shards
+	}
+	return &opt
+}
+
 func GetGroup(name string) *Group {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -66,33 +146,57 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	g.peers = peers
 }
 
-// Get 缓存获取（本地 -> 远程 -> 回调函数）
+// Get 缓存获取（本地 -> 热点 -> 远程 -> 回调函数）
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 同 Get，从远程节点获取时使用ctx的截止时间
+// 相同key的并发请求会被合并，合并后的请求使用第一个请求的ctx
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
+	g.stats.gets.Add(1)
 
 	// 优先从本地的缓存中获取
 	if v, ok := g.mainCache.get(key); ok {
+		g.stats.mainHits.Add(1)
 		log.Println("[GeeCache] hit")
 		return v, nil
 	}
+	if v, ok := g.hotCache.get(key); ok {
+		g.stats.hotHits.Add(1)
+		log.Println("[GeeCache] hot hit")
+		return v, nil
+	}
 
-	return g.load(key)
+	return g.load(ctx, key)
 }
 
-func (g *Group) load(key string) (value ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	g.stats.loads.Add(1)
 	// 并发场景下，针对相同的key，load过程只会调用一次
 	view, err := g.loader.Do(key, func() (any, error) {
+		g.stats.loadsDeduped.Add(1)
 		if g.peers != nil {
 			if peer, ok := g.peers.PickPeer(key); ok {
-				if value, err := g.getFromPeer(peer, key); err == nil {
+				value, err := g.getFromPeer(ctx, peer, key)
+				if err == nil {
+					g.stats.peerLoads.Add(1)
 					return value, nil
 				}
+				g.stats.peerErrors.Add(1)
 				log.Println("[GeeCache] Failed to get from peer", err)
 			}
 		}
-		return g.getLocally(key)
+		value, err := g.getLocally(key)
+		if err != nil {
+			g.stats.localLoadErrs.Add(1)
+			return nil, err
+		}
+		g.stats.localLoads.Add(1)
+		return value, nil
 	})
 	if err == nil {
 		return view.(ByteView), nil
@@ -101,28 +205,111 @@ func (g *Group) load(key string) (value ByteView, err error) {
 }
 
 // 从远程节点获取缓存
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
 	req := &geecachepb.Request{Group: g.name, Key: key}
 	resp := &geecachepb.Response{}
-	if err := peer.Get(req, resp); err != nil {
+	if err := peer.Get(ctx, req, resp); err != nil {
 		return ByteView{}, err
 	}
-	return ByteView{b: resp.Value}, nil
+	value := ByteView{b: resp.Value}
+	// 只缓存一部分远程key，频繁访问的key大概率会被放入hotCache
+	if g.hotRatio > 0 && rand.Intn(g.hotRatio) == 0 {
+		g.populateHotCache(key, value)
+	}
+	return value, nil
 }
 
 // 通过本地回调函数获取缓存
 func (g *Group) getLocally(key string) (ByteView, error) {
 	// 触发回调函数获取源数据
-	bytes, err := g.getter.Get(key)
+	var bytes []byte
+	var ttl time.Duration
+	var err error
+	if getter, ok := g.getter.(TTLGetter); ok {
+		bytes, ttl, err = getter.GetWithTTL(key)
+	} else {
+		bytes, err = g.getter.Get(key)
+	}
 	if err != nil {
 		return ByteView{}, err
 	}
 
 	value := ByteView{b: cloneBytes(bytes)}
-	g.populateCache(key, value)
+	g.populateCache(key, value, ttl)
 	return value, nil
 }
 
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+func (g *Group) populateCache(key string, value ByteView, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = g.ttl
+	}
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+	g.mainCache.add(key, value, expireAt)
+}
+
+// hotCache中的值无法得知其在peer节点上的有效期，使用缓存组的默认有效期
+func (g *Group) populateHotCache(key string, value ByteView) {
+	var expireAt time.Time
+	if g.ttl > 0 {
+		expireAt = time.Now().Add(g.ttl)
+	}
+	g.hotCache.add(key, value, expireAt)
+}
+
+// Update 在本节点上原子地读-改-写key的值，fn以当前值（不存在或已过期时为nil）计算新值，返回nil时删除key
+// 新值的有效期为ttl，为0时使用缓存组的默认有效期，为负数时永不过期
+// 只修改本节点的mainCache，不调用Getter也不转发给peer节点：多节点部署时各节点的值相互独立，
+// 需要全局一致的结果时应将同一个key的请求路由到同一节点。*Group因此可以作为gee限流中间件的状态存储
+func (g *Group) Update(key string, ttl time.Duration, fn func(value []byte) []byte) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if ttl == 0 {
+		ttl = g.ttl
+	}
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+	g.mainCache.update(key, expireAt, func(old ByteView, ok bool) (ByteView, bool) {
+		var value []byte
+		if ok {
+			value = old.ByteSlice()
+		}
+		next := fn(value)
+		return ByteView{b: cloneBytes(next)}, next != nil
+	})
+	// 本节点的hotCache中可能有该key的旧副本
+	g.hotCache.remove(key)
+	return nil
+}
+
+// Remove 删除缓存，key属于远程节点时同时通知该节点删除
+// 其他节点hotCache中的副本不会被删除，直到过期或被淘汰
+func (g *Group) Remove(key string) error {
+	return g.RemoveContext(context.Background(), key)
+}
+
+// RemoveContext 同 Remove，通知远程节点时使用ctx的截止时间
+func (g *Group) RemoveContext(ctx context.Context, key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+
+	g.removeLocally(key)
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			return peer.Remove(ctx, &geecachepb.Request{Group: g.name, Key: key})
+		}
+	}
+	return nil
+}
+
+// 只删除本地缓存，peer节点收到删除请求时调用，不再转发
+func (g *Group) removeLocally(key string) {
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
 }
@@ -1,12 +1,30 @@
 package geecache
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io/fs"
+	"learn-go/src/projects/geecache/geecachepb"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // 用一个 map 模拟耗时的数据库
@@ -57,6 +75,523 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestTTL(t *testing.T) {
+	loadCounts := make(map[string]int)
+	gee := NewGroup("ttl", 2<<10, TTLGetterFunc(func(key string) ([]byte, time.Duration, error) {
+		loadCounts[key]++
+		switch key {
+		case "short":
+			return []byte(key), 20 * time.Millisecond, nil
+		case "forever":
+			return []byte(key), -1, nil
+		}
+		return []byte(key), 0, nil
+	}), &GroupOption{TTL: 50 * time.Millisecond, CleanupInterval: 10 * time.Millisecond})
+	defer gee.mainCache.close()
+
+	for _, key := range []string{"short", "default", "forever"} {
+		_, _ = gee.Get(key)
+		_, _ = gee.Get(key)
+	}
+	time.Sleep(30 * time.Millisecond)
+	for _, key := range []string{"short", "default", "forever"} {
+		_, _ = gee.Get(key)
+	}
+	if loadCounts["short"] != 2 || loadCounts["default"] != 1 || loadCounts["forever"] != 1 {
+		t.Fatalf("per-key ttl failed, loads: %v", loadCounts)
+	}
+
+	// 后台清理不再被访问的过期缓存
+	time.Sleep(60 * time.Millisecond)
+	if n := gee.CacheStats(MainCache).Items; n != 1 {
+		t.Fatalf("expired entries should be cleaned up in background, %d left", n)
+	}
+}
+
+type fakePeer struct {
+	removed []string
+}
+
+func (p *fakePeer) PickPeer(key string) (PeerGetter, bool) {
+	return p, key == "remote"
+}
+
+func (p *fakePeer) Get(_ context.Context, in *geecachepb.Request, out *geecachepb.Response) error {
+	out.Value = []byte("peer")
+	return nil
+}
+
+func (p *fakePeer) Remove(_ context.Context, in *geecachepb.Request) error {
+	p.removed = append(p.removed, in.GetGroup()+"/"+in.GetKey())
+	return nil
+}
+
+func TestRemove(t *testing.T) {
+	loads := 0
+	gee := NewGroup("remove", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	}))
+	peer := &fakePeer{}
+	gee.RegisterPeers(peer)
+
+	_, _ = gee.Get("local")
+	if err := gee.Remove("local"); err != nil || len(peer.removed) != 0 {
+		t.Fatalf("local key should not be forwarded, got %v %v", err, peer.removed)
+	}
+	if _, _ = gee.Get("local"); loads != 2 {
+		t.Fatalf("removed key should be loaded again, loads=%d", loads)
+	}
+
+	if err := gee.Remove("remote"); err != nil || !reflect.DeepEqual(peer.removed, []string{"remove/remote"}) {
+		t.Fatalf("remote key should be forwarded to its owner, got %v %v", err, peer.removed)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	a, b := parseOptions(), parseOptions(nil)
+	if a == b || a.CleanupInterval != time.Minute || a.Shards != 16 {
+		t.Fatalf("each group should get its own default option, got %+v", a)
+	}
+	a.Shards = 1
+	if parseOptions().Shards != 16 {
+		t.Fatal("changing a group's option should not affect the default")
+	}
+	if opt := parseOptions(&GroupOption{TTL: time.Second}); opt.TTL != time.Second || opt.CleanupInterval != time.Minute || opt.Shards != 16 {
+		t.Fatalf("zero fields should fall back to the default, got %+v", opt)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	loads := 0
+	gee := NewGroup("update", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return nil, fmt.Errorf("%s not exist", key)
+	}))
+
+	// 同一个key的并发更新不会丢失
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = gee.Update("counter", time.Minute, func(value []byte) []byte {
+				return append(value, 'x')
+			})
+		}()
+	}
+	wg.Wait()
+	if view, err := gee.Get("counter"); err != nil || view.Len() != 50 || loads != 0 {
+		t.Fatalf("concurrent updates should be atomic, got %d bytes, err %v", view.Len(), err)
+	}
+
+	_ = gee.Update("short", 10*time.Millisecond, func([]byte) []byte { return []byte("v") })
+	time.Sleep(20 * time.Millisecond)
+	_ = gee.Update("short", time.Minute, func(value []byte) []byte {
+		if value != nil {
+			t.Error("expired value should be passed as nil")
+		}
+		return nil
+	})
+	if _, err := gee.Get("short"); err == nil {
+		t.Fatal("returning nil should remove the key")
+	}
+	if err := gee.Update("", time.Minute, func(value []byte) []byte { return value }); err == nil {
+		t.Fatal("empty key should be rejected")
+	}
+}
+
+func TestHotCache(t *testing.T) {
+	gee := NewGroup("hot", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), &GroupOption{HotCacheBytes: 64})
+	gee.RegisterPeers(&fakePeer{})
+	gee.hotRatio = 1
+
+	for i := 0; i < 3; i++ {
+		if view, err := gee.Get("remote"); err != nil || view.String() != "peer" {
+			t.Fatalf("failed to get remote key, got %v %v", view, err)
+		}
+		_, _ = gee.Get("local")
+	}
+	stats := gee.Stats()
+	expect := Stats{Gets: 6, MainHits: 2, HotHits: 2, Loads: 2, LoadsDeduped: 2, PeerLoads: 1, LocalLoads: 1}
+	if stats != expect {
+		t.Fatalf("expect stats %+v, got %+v", expect, stats)
+	}
+	if hot := gee.CacheStats(HotCache); hot.Items != 1 || hot.Bytes != int64(len("remotepeer")) || hot.Hits != 2 {
+		t.Fatalf("unexpected hot cache stats %+v", hot)
+	}
+
+	// hotCache有独立的容量，不会挤占mainCache
+	gee.populateHotCache("big", ByteView{b: make([]byte, 60)})
+	if gee.CacheStats(HotCache).Items != 1 || gee.CacheStats(MainCache).Items != 1 {
+		t.Fatalf("hot cache should be bounded by its own budget")
+	}
+
+	_ = gee.Remove("big")
+	if gee.CacheStats(HotCache).Items != 0 {
+		t.Fatalf("Remove should also drop the hot cache copy")
+	}
+}
+
+func TestHotCacheUnlimitedMain(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	// mainCache不限大小时默认不启用hotCache，否则hotCache也会不限大小
+	gee := NewGroup("hot-unlimited", 0, getter)
+	gee.RegisterPeers(&fakePeer{})
+	for i := 0; i < 50; i++ {
+		_, _ = gee.Get("remote")
+	}
+	if gee.hotRatio != 0 || gee.CacheStats(HotCache).Items != 0 {
+		t.Fatalf("hot cache should be disabled, got %+v", gee.CacheStats(HotCache))
+	}
+
+	// 明确指定大小时仍然启用
+	if g := NewGroup("hot-explicit", 0, getter, &GroupOption{HotCacheBytes: 64}); g.hotRatio == 0 {
+		t.Fatal("explicit hot cache size should enable the hot cache")
+	}
+}
+
+var policies = []struct {
+	name string
+	fn   PolicyFunc
+}{
+	{"LRU", LRU},
+	{"LFU", LFU},
+	{"ARC", ARC},
+	{"TinyLFU", TinyLFU},
+}
+
+func TestPolicies(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			gee := NewGroup("policy-"+p.name, 64, GetterFunc(func(key string) ([]byte, error) {
+				return []byte(key), nil
+			}), &GroupOption{Policy: p.fn, HotCacheBytes: -1})
+			for i := 0; i < 100; i++ {
+				key := "key0"
+				if i%2 == 1 {
+					key = fmt.Sprintf("key%d", i%20)
+				}
+				if view, err := gee.Get(key); err != nil || view.String() != key {
+					t.Fatalf("failed to get %s", key)
+				}
+			}
+			if stats := gee.CacheStats(MainCache); stats.Bytes > 64 || stats.Items == 0 || stats.Hits == 0 {
+				t.Fatalf("unexpected cache stats %+v", stats)
+			}
+
+			policy := p.fn(0)
+			policy.AddWithExpire("expired", ByteView{b: []byte("v")}, time.Now().Add(-time.Second))
+			policy.AddWithExpire("alive", ByteView{b: []byte("v")}, time.Time{})
+			if _, ok := policy.Get("expired"); ok || policy.Len() != 1 || policy.RemoveExpired() != 0 {
+				t.Fatalf("expired entries should not be returned")
+			}
+			if !policy.Remove("alive") || policy.Len() != 0 || policy.Bytes() != 0 {
+				t.Fatalf("Remove failed, len=%d bytes=%d", policy.Len(), policy.Bytes())
+			}
+		})
+	}
+}
+
+// 生成访问序列：n次服从zipf分布的热点访问，scan为true时每隔10000次访问插入一次5000个新key的扫描（如夜间报表任务）
+func zipfTrace(n int, scan bool) []string {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 1<<16)
+	trace := make([]string, 0, n)
+	for i := 0; len(trace) < n; i++ {
+		if scan && i > 0 && i%10000 == 0 {
+			for j := 0; j < 5000; j++ {
+				trace = append(trace, fmt.Sprintf("scan-%d-%d", i, j))
+			}
+		}
+		trace = append(trace, fmt.Sprintf("key-%d", zipf.Uint64()))
+	}
+	return trace[:n]
+}
+
+// 生成访问序列：循环访问比缓存略大的key集合，LRU在这种情况下一次都不会命中
+func loopTrace(n, keys int) []string {
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = fmt.Sprintf("key-%d", i%keys)
+	}
+	return trace
+}
+
+var traceFile = flag.String("keytrace", "testdata/trace.txt", "access trace used by BenchmarkHitRatio, one key per line")
+
+// 从文件读取真实的访问序列，每行的第一个字段为key，空行和#开头的行忽略
+func loadTrace(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var trace []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		trace = append(trace, fields[0])
+	}
+	return trace, scanner.Err()
+}
+
+// 比较各淘汰策略在不同访问序列下的命中率，结果中的hit%即命中率
+// 除了生成的序列，还会读取 -keytrace 指定的访问记录文件（默认testdata/trace.txt），文件不存在时跳过
+//
+//	go test -run '^$' -bench HitRatio -keytrace /path/to/trace.txt
+func BenchmarkHitRatio(b *testing.B) {
+	const cacheBytes = 1000 * 20 // 约可缓存1000个key
+	type namedTrace struct {
+		name  string
+		trace []string
+	}
+	traces := []namedTrace{
+		{"zipf", zipfTrace(200000, false)},
+		{"zipf+scan", zipfTrace(200000, true)},
+		{"loop", loopTrace(200000, 1200)},
+	}
+	fileTrace, err := loadTrace(*traceFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		b.Run("file", func(b *testing.B) {
+			b.Skipf("trace file %s not found", *traceFile)
+		})
+	case err != nil:
+		b.Fatal(err)
+	default:
+		traces = append(traces, namedTrace{"file", fileTrace})
+	}
+	value := ByteView{b: make([]byte, 10)}
+
+	for _, tr := range traces {
+		for _, p := range policies {
+			b.Run(tr.name+"/"+p.name, func(b *testing.B) {
+				hits, gets := 0, 0
+				for i := 0; i < b.N; i++ {
+					policy := p.fn(cacheBytes)
+					for _, key := range tr.trace {
+						if _, ok := policy.Get(key); ok {
+							hits++
+						} else {
+							policy.AddWithExpire(key, value, time.Time{})
+						}
+						gets++
+					}
+				}
+				b.ReportMetric(100*float64(hits)/float64(gets), "hit%")
+			})
+		}
+	}
+}
+
+func TestShards(t *testing.T) {
+	c := newCache(1<<20+5, 16, nil, 0)
+	var total int64
+	for _, s := range c.shards {
+		total += s.cacheBytes
+	}
+	if len(c.shards) != 16 || total != 1<<20+5 {
+		t.Fatalf("expect 16 shards sharing %d bytes, got %d shards with %d bytes", 1<<20+5, len(c.shards), total)
+	}
+	if n := len(newCache(2<<10, 16, nil, 0).shards); n != 1 {
+		t.Fatalf("small caches should not be sharded, got %d shards", n)
+	}
+	if n := len(newCache(0, 8, nil, 0).shards); n != 8 {
+		t.Fatalf("unlimited caches should use all shards, got %d", n)
+	}
+
+	value := ByteView{b: make([]byte, 1000)}
+	for i := 0; i < 10000; i++ {
+		c.add(fmt.Sprintf("key%d", i), value, time.Time{})
+	}
+	if stats := c.stats(); stats.Bytes > 1<<20+5 || stats.Items < 900 {
+		t.Fatalf("each shard should be bounded by its own budget, got %+v", stats)
+	}
+	for _, s := range c.shards {
+		if s.policy.Bytes() > s.cacheBytes {
+			t.Fatalf("shard exceeds its budget: %d > %d", s.policy.Bytes(), s.cacheBytes)
+		}
+	}
+}
+
+func TestCleanupStart(t *testing.T) {
+	c := newCache(0, 1, nil, time.Hour)
+	c.add("forever", ByteView{b: []byte("v")}, time.Time{})
+	if c.started.Load() || c.stop != nil {
+		t.Fatal("cleanup should wait for an expiring entry")
+	}
+	c.add("expiring", ByteView{b: []byte("v")}, time.Now().Add(time.Minute))
+	stop := c.stop
+	if !c.started.Load() || stop == nil {
+		t.Fatal("cleanup should start with the first expiring entry")
+	}
+	c.add("expiring2", ByteView{b: []byte("v")}, time.Now().Add(time.Minute))
+	if c.stop != stop {
+		t.Fatal("cleanup should start only once")
+	}
+
+	// 关闭后不再启动后台清理
+	c.close()
+	c.add("late", ByteView{b: []byte("v")}, time.Now().Add(time.Minute))
+	if c.stop != nil {
+		t.Fatal("cleanup should not restart after close")
+	}
+}
+
+// 并发读写缓存，分片后不同key的访问不再竞争同一把锁
+//
+//	go test -run '^$' -bench CacheParallel -cpu 1,2,4,8
+func BenchmarkCacheParallel(b *testing.B) {
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	value := ByteView{b: make([]byte, 64)}
+
+	for _, shards := range []int{1, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := newCache(16<<20, shards, nil, 0)
+			for _, key := range keys {
+				c.add(key, value, time.Time{})
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(keys))
+				for pb.Next() {
+					key := keys[i%len(keys)]
+					if i%10 == 0 {
+						c.add(key, value, time.Time{})
+					} else {
+						c.get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestHTTPRemove(t *testing.T) {
+	loads := 0
+	NewGroup("http-remove", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	}))
+	server := httptest.NewServer(NewHTTPPool("self"))
+	defer server.Close()
+
+	getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+	req := &geecachepb.Request{Group: "http-remove", Key: "Tom"}
+	for i := 0; i < 2; i++ {
+		if err := getter.Get(context.Background(), req, &geecachepb.Response{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := getter.Remove(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	resp := &geecachepb.Response{}
+	if err := getter.Get(context.Background(), req, resp); err != nil || string(resp.GetValue()) != "Tom" || loads != 2 {
+		t.Fatalf("key should be reloaded after remove, loads=%d", loads)
+	}
+	if err := getter.Remove(context.Background(), &geecachepb.Request{Group: "unknown", Key: "Tom"}); err == nil {
+		t.Fatalf("removing from an unknown group should fail")
+	}
+}
+
+func TestGRPCPool(t *testing.T) {
+	var loads atomic.Int32
+	NewGroup("grpc", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		if key == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return []byte(key), nil
+	}))
+
+	// 在内存中启动peer-b的gRPC服务
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	geecachepb.RegisterGroupCacheServer(server, NewGRPCPool("peer-b"))
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		if addr != "peer-b" {
+			return nil, fmt.Errorf("unknown peer %s", addr)
+		}
+		return lis.DialContext(ctx)
+	})
+	pool := NewGRPCPool("peer-a", dialer)
+	defer func() { _ = pool.Close() }()
+	if err := pool.Set("peer-a", "peer-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 找到一个属于peer-b的key
+	var key string
+	var peer PeerGetter
+	for i := 0; peer == nil; i++ {
+		key = fmt.Sprintf("key%d", i)
+		peer, _ = pool.PickPeer(key)
+	}
+	ctx := context.Background()
+	req := &geecachepb.Request{Group: "grpc", Key: key}
+	for i := 0; i < 2; i++ {
+		resp := &geecachepb.Response{}
+		if err := peer.Get(ctx, req, resp); err != nil || string(resp.GetValue()) != key || loads.Load() != 1 {
+			t.Fatalf("failed to get %s from peer, err=%v loads=%d", key, err, loads.Load())
+		}
+	}
+	if err := peer.Remove(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.Get(ctx, req, &geecachepb.Response{}); err != nil || loads.Load() != 2 {
+		t.Fatalf("key should be reloaded after remove, err=%v loads=%d", err, loads.Load())
+	}
+
+	err := peer.Get(ctx, &geecachepb.Request{Group: "unknown", Key: key}, &geecachepb.Response{})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expect NotFound for an unknown group, got %v", err)
+	}
+
+	// 调用方的截止时间传递到peer节点
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = peer.Get(timeout, &geecachepb.Request{Group: "grpc", Key: "slow"}, &geecachepb.Response{})
+	if status.Code(err) != codes.DeadlineExceeded || time.Since(start) > 150*time.Millisecond {
+		t.Fatalf("expect DeadlineExceeded before the slow load finishes, got %v after %v", err, time.Since(start))
+	}
+
+	// 重新设置节点时复用已有的连接，关闭被移除节点的连接
+	conn := peer.(*grpcGetter).conn
+	if err := pool.Set("peer-a", "peer-b", "peer-c"); err != nil {
+		t.Fatal(err)
+	}
+	if pool.grpcGetters["peer-b"].conn != conn {
+		t.Fatalf("connection to peer-b should be reused")
+	}
+	if err := pool.Set("peer-a"); err != nil {
+		t.Fatal(err)
+	}
+	if conn.GetState() != connectivity.Shutdown {
+		t.Fatalf("connection to a removed peer should be closed, got %v", conn.GetState())
+	}
+	if _, ok := pool.PickPeer(key); ok {
+		t.Fatalf("only self is left, no peer should be picked")
+	}
+}
+
 func createGroup() *Group {
 	return NewGroup("scores", 2<<10, GetterFunc(
 		func(key string) ([]byte, error) {
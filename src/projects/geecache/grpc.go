@@ -0,0 +1,159 @@
+package geecache
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"learn-go/src/projects/geecache/consistenthash"
+	"learn-go/src/projects/geecache/geecachepb"
+	"log"
+	"sync"
+)
+
+// GRPCPool 基于gRPC的分布式缓存系统节点通信，既是GroupCache服务的实现，也是peer节点选择器
+// 与每个peer节点保持一个长连接，调用方ctx的截止时间随请求传递到peer节点
+type GRPCPool struct {
+	geecachepb.UnimplementedGroupCacheServer
+
+	self        string                 // 记录自己的地址 如：localhost:8001
+	opts        []grpc.DialOption      // 连接peer节点的选项
+	mu          sync.Mutex             // 互斥锁
+	peers       *consistenthash.Map    // 节点哈希环
+	grpcGetters map[string]*grpcGetter // 远程节点与其gRPC客户端的映射
+}
+
+// NewGRPCPool opts用于连接peer节点，默认不使用TLS
+func NewGRPCPool(self string, opts ...grpc.DialOption) *GRPCPool {
+	return &GRPCPool{
+		self:        self,
+		opts:        append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...),
+		grpcGetters: make(map[string]*grpcGetter),
+	}
+}
+
+func (p *GRPCPool) Log(format string, v ...any) {
+	log.Printf("[Server %s] %s", p.self, fmt.Sprintf(format, v...))
+}
+
+// Get 提供gRPC服务的能力
+func (p *GRPCPool) Get(ctx context.Context, in *geecachepb.Request) (*geecachepb.Response, error) {
+	p.Log("Get %s/%s", in.GetGroup(), in.GetKey())
+
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "no such group: %s", in.GetGroup())
+	}
+	view, err := group.GetContext(ctx, in.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	return &geecachepb.Response{Value: view.ByteSlice()}, nil
+}
+
+// Remove 删除缓存，只删除本节点的缓存
+func (p *GRPCPool) Remove(ctx context.Context, in *geecachepb.Request) (*geecachepb.Response, error) {
+	p.Log("Remove %s/%s", in.GetGroup(), in.GetKey())
+
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "no such group: %s", in.GetGroup())
+	}
+	group.removeLocally(in.GetKey())
+	return &geecachepb.Response{}, nil
+}
+
+// Set 设置远程peer节点，已有的连接会被复用，不再是peer节点的连接会被关闭
+func (p *GRPCPool) Set(peers ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 节点上环
+	p.peers = consistenthash.New(defaultReplicas, nil)
+	p.peers.Add(peers...)
+
+	// 节点绑定gRPC客户端
+	getters := make(map[string]*grpcGetter, len(peers))
+	for _, peer := range peers {
+		if getter, ok := p.grpcGetters[peer]; ok {
+			getters[peer] = getter
+			continue
+		}
+		// 连接是惰性建立的，这里只会因为选项错误而失败
+		conn, err := grpc.Dial(peer, p.opts...)
+		if err != nil {
+			return err
+		}
+		getters[peer] = &grpcGetter{conn: conn, client: geecachepb.NewGroupCacheClient(conn)}
+	}
+	for peer, getter := range p.grpcGetters {
+		if _, ok := getters[peer]; !ok {
+			_ = getter.conn.Close()
+		}
+	}
+	p.grpcGetters = getters
+	return nil
+}
+
+// PickPeer 基于gRPC客户端的peer节点选择器
+func (p *GRPCPool) PickPeer(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 从哈希环上选择
+	if p.peers == nil {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+		p.Log("Pick peer %s", peer)
+		return p.grpcGetters[peer], true
+	}
+	return nil, false
+}
+
+// Close 关闭与所有peer节点的连接
+func (p *GRPCPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for _, getter := range p.grpcGetters {
+		if e := getter.conn.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	p.grpcGetters = make(map[string]*grpcGetter)
+	p.peers = nil
+	return err
+}
+
+var (
+	_ PeerPicker                  = (*GRPCPool)(nil)
+	_ geecachepb.GroupCacheServer = (*GRPCPool)(nil)
+)
+
+// gRPC客户端
+type grpcGetter struct {
+	conn   *grpc.ClientConn // 与peer节点的长连接
+	client geecachepb.GroupCacheClient
+}
+
+// Get 调用peer节点的Get方法获取缓存
+func (g *grpcGetter) Get(ctx context.Context, in *geecachepb.Request, out *geecachepb.Response) error {
+	resp, err := g.client.Get(ctx, in)
+	if err != nil {
+		return err
+	}
+	out.Value = resp.GetValue()
+	return nil
+}
+
+// Remove 调用peer节点的Remove方法删除缓存
+func (g *grpcGetter) Remove(ctx context.Context, in *geecachepb.Request) error {
+	_, err := g.client.Remove(ctx, in)
+	return err
+}
+
+var _ PeerGetter = (*grpcGetter)(nil)
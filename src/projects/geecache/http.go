@@ -1,6 +1,7 @@
 package geecache
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang/protobuf/proto"
 	"io"
@@ -64,8 +65,15 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 删除缓存，只删除本节点的缓存
+	if r.Method == http.MethodDelete {
+		group.removeLocally(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	// 缓存值
-	view, err := group.Get(key)
+	view, err := group.GetContext(r.Context(), key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -122,10 +130,12 @@ type httpGetter struct {
 }
 
 // Get 发送http get请求从其他peer节点获取缓存
-func (h *httpGetter) Get(in *geecachepb.Request, out *geecachepb.Response) error {
-	u := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(in.GetGroup()), url.QueryEscape(in.GetKey()))
-
-	resp, err := http.Get(u)
+func (h *httpGetter) Get(ctx context.Context, in *geecachepb.Request, out *geecachepb.Response) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url(in), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -150,4 +160,26 @@ func (h *httpGetter) Get(in *geecachepb.Request, out *geecachepb.Response) error
 	return nil
 }
 
+// Remove 发送http delete请求删除其他peer节点的缓存
+func (h *httpGetter) Remove(ctx context.Context, in *geecachepb.Request) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, h.url(in), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("server returned: %v", resp.Status)
+	}
+	return nil
+}
+
+func (h *httpGetter) url(in *geecachepb.Request) string {
+	return fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(in.GetGroup()), url.QueryEscape(in.GetKey()))
+}
+
 var _ PeerGetter = (*httpGetter)(nil)
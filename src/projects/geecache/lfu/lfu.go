@@ -0,0 +1,167 @@
+package lfu
+
+import (
+	"container/heap"
+	"learn-go/src/projects/geecache/lru"
+	"time"
+)
+
+// Value 与 lru.Value 相同，便于不同淘汰策略互相替换
+type Value = lru.Value
+
+// Cache LFU缓存，淘汰访问次数最少的缓存，次数相同时淘汰最久未访问的
+// 只被访问过一次的缓存最先被淘汰，因此一次性的扫描不会冲掉频繁访问的缓存
+type Cache struct {
+	maxBytes  int64                         // 最大内存大小
+	nBytes    int64                         // 占用的内存大小
+	heap      entryHeap                     // 按访问次数排序的小顶堆
+	cache     map[string]*entry             // 数据字典
+	tick      uint64                        // 逻辑时钟，记录最近一次访问的先后
+	OnEvicted func(key string, value Value) // 回调函数（淘汰、过期和删除时都会调用）
+	now       func() time.Time              // 时钟，便于测试
+}
+
+type entry struct {
+	key      string
+	value    Value
+	expireAt time.Time // 过期时间，零值表示永不过期
+	freq     int       // 访问次数
+	tick     uint64    // 最近一次访问的逻辑时间
+	index    int       // 在堆中的下标
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		cache:     make(map[string]*entry),
+		OnEvicted: onEvicted,
+		now:       time.Now,
+	}
+}
+
+// Get 获取缓存并增加访问次数，已过期的缓存在此时惰性删除
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	e, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(c.now()) {
+		c.removeEntry(e)
+		return nil, false
+	}
+	c.touch(e)
+	return e.value, true
+}
+
+// Add 新增/更新永不过期的缓存
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 新增/更新缓存，expireAt为零值时永不过期，更新也算作一次访问
+func (c *Cache) AddWithExpire(key string, value Value, expireAt time.Time) {
+	if e, ok := c.cache[key]; ok {
+		c.nBytes += int64(value.Len()) - int64(e.value.Len())
+		e.value, e.expireAt = value, expireAt
+		c.touch(e)
+	} else {
+		c.tick++
+		e := &entry{key: key, value: value, expireAt: expireAt, freq: 1, tick: c.tick}
+		heap.Push(&c.heap, e)
+		c.cache[key] = e
+		c.nBytes += int64(len(key)) + int64(value.Len())
+	}
+	// 触发内存淘汰操作
+	for c.maxBytes != 0 && c.maxBytes < c.nBytes {
+		c.RemoveOldest()
+	}
+}
+
+// RemoveOldest 淘汰访问次数最少的缓存
+func (c *Cache) RemoveOldest() {
+	if len(c.heap) > 0 {
+		c.removeEntry(c.heap[0])
+	}
+}
+
+// Remove 删除指定的缓存，返回缓存是否存在
+func (c *Cache) Remove(key string) bool {
+	if e, ok := c.cache[key]; ok {
+		c.removeEntry(e)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 删除全部已过期的缓存，返回删除的数量
+func (c *Cache) RemoveExpired() int {
+	now, n := c.now(), 0
+	for _, e := range c.cache {
+		if e.expired(now) {
+			c.removeEntry(e)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Cache) Len() int {
+	return len(c.heap)
+}
+
+// Bytes 占用的内存大小
+func (c *Cache) Bytes() int64 {
+	return c.nBytes
+}
+
+func (c *Cache) touch(e *entry) {
+	c.tick++
+	e.freq++
+	e.tick = c.tick
+	heap.Fix(&c.heap, e.index)
+}
+
+func (c *Cache) removeEntry(e *entry) {
+	heap.Remove(&c.heap, e.index)
+	delete(c.cache, e.key)
+	c.nBytes -= int64(len(e.key)) + int64(e.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// 实现 heap.Interface，堆顶是访问次数最少且最久未访问的缓存
+type entryHeap []*entry
+
+func (h entryHeap) Len() int { return len(h) }
+
+func (h entryHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,54 @@
+package lfu
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (s String) Len() int {
+	return len(s)
+}
+
+func TestRemoveOldest(t *testing.T) {
+	var evicted []string
+	lfu := New(int64(12), func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	lfu.Add("k3", String("v3"))
+	lfu.Get("k1")
+	lfu.Get("k1")
+	lfu.Get("k2")
+
+	// k3访问次数最少，其次是k2
+	lfu.Add("k4", String("v4"))
+	lfu.Add("k5", String("v5"))
+	if !reflect.DeepEqual(evicted, []string{"k3", "k4"}) {
+		t.Fatalf("unexpected eviction order %v", evicted)
+	}
+	if _, ok := lfu.Get("k1"); !ok || lfu.Len() != 3 || lfu.Bytes() != 12 {
+		t.Fatalf("frequent key k1 should stay, len=%d bytes=%d", lfu.Len(), lfu.Bytes())
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	lfu := New(int64(0), nil)
+	lfu.now = func() time.Time { return now }
+	lfu.AddWithExpire("k1", String("v1"), now.Add(time.Second))
+	lfu.AddWithExpire("k2", String("v2"), now.Add(time.Minute))
+	lfu.Add("k3", String("v3"))
+
+	now = now.Add(time.Second)
+	if _, ok := lfu.Get("k1"); ok || lfu.Len() != 2 {
+		t.Fatalf("k1 should expire on access")
+	}
+	now = now.Add(time.Hour)
+	if n := lfu.RemoveExpired(); n != 1 || lfu.Len() != 1 || !lfu.Remove("k3") || lfu.Bytes() != 0 {
+		t.Fatalf("RemoveExpired removed %d, len=%d", n, lfu.Len())
+	}
+}
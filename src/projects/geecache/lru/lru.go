@@ -1,19 +1,28 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"time"
+)
 
 type Cache struct {
 	maxBytes  int64                         // 最大内存大小
 	nBytes    int64                         // 占用的内存大小
 	ll        *list.List                    // 双向链表
 	cache     map[string]*list.Element      // 数据字典 值指向双向链表中的节点
-	OnEvicted func(key string, value Value) // 回调函数
+	OnEvicted func(key string, value Value) // 回调函数（淘汰、过期和删除时都会调用）
+	now       func() time.Time              // 时钟，便于测试
 }
 
 // 双向链表的数据类型
 type entry struct {
-	key   string // 链表中冗余存储key，当淘汰首节点时通过key从字典中删除对应的映射
-	value Value  // 值是实现了Value接口的任意类型
+	key      string    // 链表中冗余存储key，当淘汰首节点时通过key从字典中删除对应的映射
+	value    Value     // 值是实现了Value接口的任意类型
+	expireAt time.Time // 过期时间，零值表示永不过期
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
 }
 
 type Value interface {
@@ -26,16 +35,21 @@ func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
 		ll:        list.New(),
 		cache:     make(map[string]*list.Element),
 		OnEvicted: onEvicted,
+		now:       time.Now,
 	}
 }
 
-// Get 获取缓存
+// Get 获取缓存，已过期的缓存在此时惰性删除
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	// 先从字典中根据键获取值
 	if elem, ok := c.cache[key]; ok {
+		kv := elem.Value.(*entry)
+		if kv.expired(c.now()) {
+			c.removeElement(elem)
+			return nil, false
+		}
 		// 键存在，则移动到链表尾部
 		c.ll.MoveToFront(elem)
-		kv := elem.Value.(*entry)
 		return kv.value, true
 	}
 	return
@@ -44,28 +58,60 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 // RemoveOldest 缓存淘汰
 func (c *Cache) RemoveOldest() {
 	// 获取队首节点，淘汰
-	elem := c.ll.Back()
-	if elem != nil {
-		c.ll.Remove(elem)
-		kv := elem.Value.(*entry)
-		delete(c.cache, kv.key)
-		c.nBytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
+	if elem := c.ll.Back(); elem != nil {
+		c.removeElement(elem)
+	}
+}
+
+// Remove 删除指定的缓存，返回缓存是否存在
+func (c *Cache) Remove(key string) bool {
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 删除全部已过期的缓存，返回删除的数量，供后台定期清理使用
+func (c *Cache) RemoveExpired() int {
+	now, n := c.now(), 0
+	for elem := c.ll.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*entry).expired(now) {
+			c.removeElement(elem)
+			n++
 		}
+		elem = prev
+	}
+	return n
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	kv := elem.Value.(*entry)
+	delete(c.cache, kv.key)
+	c.nBytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
 	}
 }
 
-// Add 新增/更新缓存
+// Add 新增/更新永不过期的缓存
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 新增/更新缓存，expireAt为零值时永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expireAt time.Time) {
 	if elem, ok := c.cache[key]; ok {
 		// 更新操作
 		c.ll.MoveToFront(elem)
 		kv := elem.Value.(*entry)
 		c.nBytes += int64(value.Len()) - int64(kv.value.Len())
+		kv.value, kv.expireAt = value, expireAt
 	} else {
 		// 新增操作
-		elem := c.ll.PushFront(&entry{key, value})
+		elem := c.ll.PushFront(&entry{key, value, expireAt})
 		c.cache[key] = elem
 		c.nBytes += int64(len(key)) + int64(value.Len())
 	}
@@ -78,3 +124,8 @@ func (c *Cache) Add(key string, value Value) {
 func (c *Cache) Len() int {
 	return c.ll.Len()
 }
+
+// Bytes 占用的内存大小
+func (c *Cache) Bytes() int64 {
+	return c.nBytes
+}
@@ -3,6 +3,7 @@ package lru
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -53,3 +54,47 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	evicted := 0
+	lru := New(int64(0), func(string, Value) { evicted++ })
+	lru.now = func() time.Time { return now }
+
+	lru.AddWithExpire("key1", String("1234"), now.Add(time.Second))
+	lru.AddWithExpire("key2", String("5678"), now.Add(time.Minute))
+	lru.Add("key3", String("forever"))
+	if _, ok := lru.Get("key1"); !ok {
+		t.Fatalf("key1 should not expire yet")
+	}
+
+	// 访问时惰性删除
+	now = now.Add(time.Second)
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 2 || evicted != 1 {
+		t.Fatalf("key1 should expire on access, len=%d", lru.Len())
+	}
+
+	// 定期清理时批量删除，永不过期的缓存保留
+	now = now.Add(time.Hour)
+	if n := lru.RemoveExpired(); n != 1 || lru.Len() != 1 || lru.nBytes != int64(len("key3forever")) {
+		t.Fatalf("RemoveExpired removed %d, len=%d, bytes=%d", n, lru.Len(), lru.nBytes)
+	}
+
+	// 更新时同时更新过期时间
+	lru.AddWithExpire("key3", String("v3"), now.Add(time.Second))
+	now = now.Add(time.Second)
+	if _, ok := lru.Get("key3"); ok {
+		t.Fatalf("updated expiration of key3 should take effect")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	if !lru.Remove("key1") || lru.Remove("key1") {
+		t.Fatalf("Remove key1 failed")
+	}
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 0 || lru.nBytes != 0 {
+		t.Fatalf("key1 should be removed")
+	}
+}
@@ -1,6 +1,7 @@
 package geecache
 
 import (
+	"context"
 	"learn-go/src/projects/geecache/geecachepb"
 )
 
@@ -12,6 +13,8 @@ type PeerPicker interface {
 
 // PeerGetter peer节点的缓存获取器
 type PeerGetter interface {
-	// Get 从peer节点中获取缓存
-	Get(in *geecachepb.Request, out *geecachepb.Response) error
+	// Get 从peer节点中获取缓存，ctx的截止时间和取消会传递给peer节点
+	Get(ctx context.Context, in *geecachepb.Request, out *geecachepb.Response) error
+	// Remove 删除peer节点中的缓存
+	Remove(ctx context.Context, in *geecachepb.Request) error
 }
@@ -0,0 +1,39 @@
+package geecache
+
+import (
+	"learn-go/src/projects/geecache/arc"
+	"learn-go/src/projects/geecache/lfu"
+	"learn-go/src/projects/geecache/lru"
+	"learn-go/src/projects/geecache/tinylfu"
+	"time"
+)
+
+// Policy 缓存淘汰策略，由 cache 加锁保护，实现不需要并发安全
+type Policy interface {
+	// Get 获取缓存，已过期的缓存视为不存在
+	Get(key string) (value lru.Value, ok bool)
+	// AddWithExpire 新增/更新缓存，超出容量时按策略淘汰，expireAt为零值时永不过期
+	AddWithExpire(key string, value lru.Value, expireAt time.Time)
+	// Remove 删除指定的缓存
+	Remove(key string) bool
+	// RemoveExpired 删除全部已过期的缓存
+	RemoveExpired() int
+	// Len 缓存数量
+	Len() int
+	// Bytes 占用的内存大小
+	Bytes() int64
+}
+
+// PolicyFunc 按最大内存大小创建淘汰策略，maxBytes为0时不限制大小
+type PolicyFunc func(maxBytes int64) Policy
+
+var (
+	// LRU 淘汰最久未访问的缓存，默认策略
+	LRU PolicyFunc = func(maxBytes int64) Policy { return lru.New(maxBytes, nil) }
+	// LFU 淘汰访问次数最少的缓存，适合热点稳定的场景
+	LFU PolicyFunc = func(maxBytes int64) Policy { return lfu.New(maxBytes, nil) }
+	// ARC 在最近访问和频繁访问之间自适应调整，能抵抗扫描
+	ARC PolicyFunc = func(maxBytes int64) Policy { return arc.New(maxBytes, nil) }
+	// TinyLFU W-TinyLFU，按访问频率决定新缓存能否进入主缓存，能抵抗扫描
+	TinyLFU PolicyFunc = func(maxBytes int64) Policy { return tinylfu.New(maxBytes, nil) }
+)
@@ -0,0 +1,72 @@
+package geecache
+
+import "sync/atomic"
+
+// Stats 缓存组的统计数据
+type Stats struct {
+	Gets          int64 // Get请求数
+	MainHits      int64 // 命中mainCache的次数
+	HotHits       int64 // 命中hotCache的次数
+	Loads         int64 // 未命中缓存的次数
+	LoadsDeduped  int64 // 经过singleflight合并后实际加载的次数
+	PeerLoads     int64 // 从peer节点获取成功的次数
+	PeerErrors    int64 // 从peer节点获取失败的次数
+	LocalLoads    int64 // 通过本地回调获取成功的次数
+	LocalLoadErrs int64 // 通过本地回调获取失败的次数
+}
+
+// 并发安全的统计计数器
+type groupStats struct {
+	gets          atomic.Int64
+	mainHits      atomic.Int64
+	hotHits       atomic.Int64
+	loads         atomic.Int64
+	loadsDeduped  atomic.Int64
+	peerLoads     atomic.Int64
+	peerErrors    atomic.Int64
+	localLoads    atomic.Int64
+	localLoadErrs atomic.Int64
+}
+
+// Stats 返回统计数据的快照
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:          g.stats.gets.Load(),
+		MainHits:      g.stats.mainHits.Load(),
+		HotHits:       g.stats.hotHits.Load(),
+		Loads:         g.stats.loads.Load(),
+		LoadsDeduped:  g.stats.loadsDeduped.Load(),
+		PeerLoads:     g.stats.peerLoads.Load(),
+		PeerErrors:    g.stats.peerErrors.Load(),
+		LocalLoads:    g.stats.localLoads.Load(),
+		LocalLoadErrs: g.stats.localLoadErrs.Load(),
+	}
+}
+
+// CacheType 缓存组内的缓存类型
+type CacheType int
+
+const (
+	MainCache CacheType = iota + 1 // 保存本节点负责的key
+	HotCache                       // 保存远程节点负责的热点key
+)
+
+// CacheStats 单个缓存的统计数据
+type CacheStats struct {
+	Bytes int64 // 占用的内存大小
+	Items int64 // 缓存数量
+	Gets  int64 // 查询次数
+	Hits  int64 // 命中次数
+}
+
+// CacheStats 返回指定缓存的统计数据
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.stats()
+	case HotCache:
+		return g.hotCache.stats()
+	default:
+		return CacheStats{}
+	}
+}
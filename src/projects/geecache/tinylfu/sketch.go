@@ -0,0 +1,134 @@
+package tinylfu
+
+import "hash/maphash"
+
+// 访问频率的估算：count-min sketch + doorkeeper
+// doorkeeper是一个布隆过滤器，key第一次出现时只记录在doorkeeper中，避免大量只出现一次的key占用计数器
+// 计数器为4位，每记录 sampleSize 次访问后全部减半，使频率随时间衰减，过去的热点不会一直占据缓存
+type sketch struct {
+	seed       maphash.Seed
+	counters   []uint64 // 每个uint64保存16个4位计数器
+	door       []uint64 // doorkeeper的位图
+	mask       uint64   // 计数器数量-1
+	additions  int      // 上次衰减后记录的访问次数
+	sampleSize int      // 衰减的周期
+}
+
+// width为计数器的数量，向上取整为2的幂
+func newSketch(width int) *sketch {
+	n := 16
+	for n < width {
+		n <<= 1
+	}
+	return &sketch{
+		seed:       maphash.MakeSeed(),
+		counters:   make([]uint64, n/16),
+		door:       make([]uint64, n/64+1),
+		mask:       uint64(n - 1),
+		sampleSize: 10 * n,
+	}
+}
+
+// 扩容到width个计数器，沿用哈希种子
+// 计数器数量都是2的幂，扩容后下标的低位不变，每个新计数器继承旧sketch中对应计数器的值，已记录的频率不会丢失
+// doorkeeper的位图大小不是2的幂，无法折叠，需要调用 carryDoor 迁移
+func (s *sketch) grow(width int) *sketch {
+	g := newSketch(width)
+	g.seed = s.seed
+	for i := range g.counters {
+		g.counters[i] = s.counters[i%len(s.counters)]
+	}
+	g.additions = s.additions
+	return g
+}
+
+// 将key在doorkeeper中的记录迁移到扩容后的g中
+func (s *sketch) carryDoor(g *sketch, key string) {
+	if h1, h2 := s.hash(key); s.inDoor(h1, h2) {
+		g.admitDoor(h1, h2)
+	}
+}
+
+func (s *sketch) width() int {
+	return len(s.counters) * 16
+}
+
+// 4组哈希函数由一次64位哈希派生
+func (s *sketch) hash(key string) (uint64, uint64) {
+	h := maphash.String(s.seed, key)
+	return h, h>>32 | h<<32
+}
+
+// 记录一次访问
+func (s *sketch) increment(key string) {
+	h1, h2 := s.hash(key)
+	if !s.admitDoor(h1, h2) {
+		return
+	}
+	for i := uint64(0); i < 4; i++ {
+		s.inc((h1 + i*h2) & s.mask)
+	}
+	if s.additions++; s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// 估算访问频率
+func (s *sketch) estimate(key string) int {
+	h1, h2 := s.hash(key)
+	freq := 15
+	for i := uint64(0); i < 4; i++ {
+		freq = min(freq, s.get((h1+i*h2)&s.mask))
+	}
+	if s.inDoor(h1, h2) {
+		freq++
+	}
+	return freq
+}
+
+func (s *sketch) get(i uint64) int {
+	return int(s.counters[i/16]>>((i%16)*4)) & 0xf
+}
+
+func (s *sketch) inc(i uint64) {
+	shift := (i % 16) * 4
+	if (s.counters[i/16]>>shift)&0xf < 15 {
+		s.counters[i/16] += 1 << shift
+	}
+}
+
+// 所有计数器减半并清空doorkeeper
+func (s *sketch) reset() {
+	for i, v := range s.counters {
+		s.counters[i] = (v >> 1) & 0x7777777777777777
+	}
+	for i := range s.door {
+		s.door[i] = 0
+	}
+	s.additions /= 2
+}
+
+// key已经在doorkeeper中时返回true，否则记录后返回false
+func (s *sketch) admitDoor(h1, h2 uint64) bool {
+	in := true
+	bits := uint64(len(s.door) * 64)
+	for i := uint64(0); i < 2; i++ {
+		b := (h1 + i*h2) % bits
+		if s.door[b/64]&(1<<(b%64)) == 0 {
+			in = false
+			s.door[b/64] |= 1 << (b % 64)
+		}
+	}
+	return in
+}
+
+func (s *sketch) inDoor(h1, h2 uint64) bool {
+	bits := uint64(len(s.door) * 64)
+	for i := uint64(0); i < 2; i++ {
+		b := (h1 + i*h2) % bits
+		if s.door[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,234 @@
+package tinylfu
+
+import (
+	"container/list"
+	"learn-go/src/projects/geecache/lru"
+	"time"
+)
+
+// Value 与 lru.Value 相同，便于不同淘汰策略互相替换
+type Value = lru.Value
+
+const (
+	windowRatio    = 0.01 // 窗口区占总容量的比例
+	protectedRatio = 0.8  // 保护区占主缓存的比例
+	minSketchWidth = 1024 // 频率计数器的最小数量
+)
+
+// Cache W-TinyLFU缓存
+// 新缓存先进入一个小的LRU窗口区，从窗口区淘汰时与主缓存中即将淘汰的缓存比较访问频率，频率更高的一方留下
+// 主缓存是分段LRU：试用区中的缓存再次被访问后晋升到保护区，保护区满时降级回试用区
+// 一次性扫描的key频率很低，无法进入主缓存，热点缓存因此不会被冲掉
+type Cache struct {
+	maxBytes  int64                         // 最大内存大小，为0时不淘汰
+	window    segment                       // 窗口区
+	probation segment                       // 试用区
+	protected segment                       // 保护区
+	cache     map[string]*list.Element      // 数据字典
+	sketch    *sketch                       // 访问频率
+	OnEvicted func(key string, value Value) // 回调函数（淘汰、过期和删除时都会调用）
+	now       func() time.Time              // 时钟，便于测试
+}
+
+// 一个LRU链表及其容量
+type segment struct {
+	ll       list.List
+	nBytes   int64
+	maxBytes int64
+}
+
+type entry struct {
+	key      string
+	value    Value
+	size     int64 // key和value占用的内存大小
+	expireAt time.Time
+	seg      *segment // 所在的链表
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	c := &Cache{
+		maxBytes:  maxBytes,
+		cache:     make(map[string]*list.Element),
+		sketch:    newSketch(minSketchWidth),
+		OnEvicted: onEvicted,
+		now:       time.Now,
+	}
+	c.window.maxBytes = max(int64(float64(maxBytes)*windowRatio), 1)
+	c.protected.maxBytes = int64(float64(maxBytes-c.window.maxBytes) * protectedRatio)
+	c.probation.maxBytes = maxBytes - c.window.maxBytes - c.protected.maxBytes
+	return c
+}
+
+// Get 获取缓存并记录访问频率，已过期的缓存在此时惰性删除
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	c.sketch.increment(key)
+	elem, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if e.expired(c.now()) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.access(elem)
+	return e.value, true
+}
+
+// Add 新增/更新永不过期的缓存
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 新增/更新缓存，expireAt为零值时永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expireAt time.Time) {
+	size := int64(len(key)) + int64(value.Len())
+	if elem, ok := c.cache[key]; ok {
+		// 更新操作
+		e := elem.Value.(*entry)
+		e.seg.nBytes += size - e.size
+		e.size, e.value, e.expireAt = size, value, expireAt
+		c.access(elem)
+	} else {
+		// 新增操作，进入窗口区
+		c.cache[key] = c.push(&c.window, &entry{key: key, value: value, size: size, expireAt: expireAt})
+	}
+	if c.maxBytes == 0 {
+		return
+	}
+	c.ensureSketch()
+	c.evict()
+}
+
+// RemoveOldest 淘汰一个缓存，依次从试用区、窗口区、保护区淘汰最久未访问的
+func (c *Cache) RemoveOldest() {
+	for _, seg := range []*segment{&c.probation, &c.window, &c.protected} {
+		if elem := seg.ll.Back(); elem != nil {
+			c.removeElement(elem)
+			return
+		}
+	}
+}
+
+// Remove 删除指定的缓存，返回缓存是否存在
+func (c *Cache) Remove(key string) bool {
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 删除全部已过期的缓存，返回删除的数量
+func (c *Cache) RemoveExpired() int {
+	now, n := c.now(), 0
+	for _, seg := range []*segment{&c.window, &c.probation, &c.protected} {
+		for elem := seg.ll.Back(); elem != nil; {
+			prev := elem.Prev()
+			if elem.Value.(*entry).expired(now) {
+				c.removeElement(elem)
+				n++
+			}
+			elem = prev
+		}
+	}
+	return n
+}
+
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes 占用的内存大小
+func (c *Cache) Bytes() int64 {
+	return c.window.nBytes + c.probation.nBytes + c.protected.nBytes
+}
+
+// 命中后调整位置：试用区的缓存晋升到保护区，保护区超出容量时把最久未访问的降级回试用区
+func (c *Cache) access(elem *list.Element) {
+	e := elem.Value.(*entry)
+	switch e.seg {
+	case &c.probation:
+		c.cache[e.key] = c.move(elem, &c.protected)
+		for c.maxBytes != 0 && c.protected.nBytes > c.protected.maxBytes && c.protected.ll.Len() > 1 {
+			back := c.protected.ll.Back()
+			c.cache[back.Value.(*entry).key] = c.move(back, &c.probation)
+		}
+	default:
+		e.seg.ll.MoveToFront(elem)
+	}
+}
+
+// 窗口区超出容量时，把窗口区最久未访问的缓存作为候选者交给主缓存准入
+func (c *Cache) evict() {
+	for c.window.nBytes > c.window.maxBytes && c.window.ll.Len() > 0 {
+		candidate := c.move(c.window.ll.Back(), &c.probation)
+		c.cache[candidate.Value.(*entry).key] = candidate
+		c.admit(candidate)
+	}
+	// 保护区的缓存被更新变大等情况下总量仍可能超出
+	for c.Bytes() > c.maxBytes {
+		c.RemoveOldest()
+	}
+}
+
+// 候选者已经放在试用区头部，主缓存超出容量时与试用区尾部的缓存（受害者）比较频率，频率低的被淘汰
+func (c *Cache) admit(candidate *list.Element) {
+	ce := candidate.Value.(*entry)
+	mainMaxBytes := c.maxBytes - c.window.maxBytes
+	for c.probation.nBytes+c.protected.nBytes > mainMaxBytes {
+		victim := c.probation.ll.Back()
+		if victim == candidate {
+			// 试用区只剩候选者，从保护区选择受害者
+			if victim = c.protected.ll.Back(); victim == nil {
+				c.removeElement(candidate)
+				return
+			}
+		}
+		if c.sketch.estimate(ce.key) > c.sketch.estimate(victim.Value.(*entry).key) {
+			c.removeElement(victim)
+		} else {
+			c.removeElement(candidate)
+			return
+		}
+	}
+}
+
+// 缓存数量超过计数器数量时扩容，保证频率估算的精度，扩容时保留已有的访问频率
+func (c *Cache) ensureSketch() {
+	if n := len(c.cache); n > c.sketch.width() {
+		g := c.sketch.grow(2 * n)
+		for key := range c.cache {
+			c.sketch.carryDoor(g, key)
+		}
+		c.sketch = g
+	}
+}
+
+func (c *Cache) push(seg *segment, e *entry) *list.Element {
+	e.seg = seg
+	seg.nBytes += e.size
+	return seg.ll.PushFront(e)
+}
+
+// 移动到seg的头部，返回新的链表节点
+func (c *Cache) move(elem *list.Element, seg *segment) *list.Element {
+	e := elem.Value.(*entry)
+	e.seg.ll.Remove(elem)
+	e.seg.nBytes -= e.size
+	return c.push(seg, e)
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	e := elem.Value.(*entry)
+	e.seg.ll.Remove(elem)
+	e.seg.nBytes -= e.size
+	delete(c.cache, e.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
@@ -0,0 +1,101 @@
+package tinylfu
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (s String) Len() int {
+	return len(s)
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(64)
+	if s.width() != 64 || s.estimate("k1") != 0 {
+		t.Fatalf("new sketch should be empty")
+	}
+	// 第一次访问只记录在doorkeeper中
+	s.increment("k1")
+	if s.estimate("k1") != 1 || s.additions != 0 {
+		t.Fatalf("first access should only set the doorkeeper, got %d", s.estimate("k1"))
+	}
+	for i := 0; i < 20; i++ {
+		s.increment("k1")
+	}
+	if freq := s.estimate("k1"); freq != 16 {
+		t.Fatalf("counters should saturate at 15, got %d", freq)
+	}
+	s.reset()
+	if freq := s.estimate("k1"); freq != 7 {
+		t.Fatalf("reset should halve counters and clear the doorkeeper, got %d", freq)
+	}
+}
+
+func TestSketchGrow(t *testing.T) {
+	s := newSketch(64)
+	for i := 0; i < 5; i++ {
+		s.increment("k1")
+	}
+	s.increment("k2")
+	g := s.grow(256)
+	for _, key := range []string{"k1", "k2"} {
+		s.carryDoor(g, key)
+	}
+	if g.width() != 256 || g.estimate("k1") != 5 || g.estimate("k2") != 1 || g.additions != s.additions {
+		t.Fatalf("grow should keep frequencies, got %d %d", g.estimate("k1"), g.estimate("k2"))
+	}
+	if g.estimate("k1") != s.estimate("k1") || g.estimate("missing") > s.estimate("missing") {
+		t.Fatal("grown sketch should estimate like the original")
+	}
+}
+
+func TestScanResistant(t *testing.T) {
+	tiny := New(int64(400), nil)
+	hot := make([]string, 20)
+	for i := range hot {
+		hot[i] = fmt.Sprintf("h%02d", i)
+	}
+	for round := 0; round < 5; round++ {
+		for _, key := range hot {
+			if _, ok := tiny.Get(key); !ok {
+				tiny.Add(key, String("vvvvvvv"))
+			}
+		}
+	}
+	// 扫描的key只出现一次，频率低于热点key，无法进入主缓存
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("s%03d", i)
+		if _, ok := tiny.Get(key); !ok {
+			tiny.Add(key, String("vvvvvv"))
+		}
+	}
+	for _, key := range hot {
+		if _, ok := tiny.Get(key); !ok {
+			t.Fatalf("hot key %s should survive the scan", key)
+		}
+	}
+	if tiny.Bytes() > 400 {
+		t.Fatalf("cache exceeds its budget: %d bytes", tiny.Bytes())
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	tiny := New(int64(0), nil)
+	tiny.now = func() time.Time { return now }
+	tiny.AddWithExpire("k1", String("v1"), now.Add(time.Second))
+	tiny.AddWithExpire("k2", String("v2"), now.Add(time.Minute))
+	tiny.Add("k3", String("v3"))
+
+	now = now.Add(time.Second)
+	if _, ok := tiny.Get("k1"); ok || tiny.Len() != 2 {
+		t.Fatalf("k1 should expire on access")
+	}
+	now = now.Add(time.Hour)
+	if n := tiny.RemoveExpired(); n != 1 || tiny.Len() != 1 || !tiny.Remove("k3") || tiny.Bytes() != 0 {
+		t.Fatalf("RemoveExpired removed %d, len=%d", n, tiny.Len())
+	}
+}